@@ -2,15 +2,25 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
+	// Event timezones must resolve even where the host has no zoneinfo
+	_ "time/tzdata"
 
 	"L3_5/internal/server"
 	"L3_5/internal/storage"
 	"L3_5/models"
 )
 
+// How long in-flight requests get to finish after a shutdown signal
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	log.Printf("=== Starting Event Booking Service ===")
 	log.Printf("Loading configuration from config.yaml")
@@ -18,8 +28,15 @@ func main() {
 	cfg := models.MustLoadConfig("config.yaml")
 	log.Printf("Configuration loaded successfully")
 
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Logging.Level)); err != nil {
+		log.Printf("Unknown logging.level %q, using info", cfg.Logging.Level)
+		level = slog.LevelInfo
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+
 	log.Printf("Initializing database connection...")
-	pool, err := storage.InitDB(cfg)
+	pool, err := storage.InitDB(cfg, logger)
 	if err != nil {
 		log.Fatal("Failed to init DB:", err)
 	}
@@ -29,18 +46,41 @@ func main() {
 	}()
 
 	log.Printf("Creating storage and server instances")
-	store := storage.New(pool)
-	srv := server.New(store)
+	var storeOpts []storage.Option
+	if cfg.Events.RejectDuplicates {
+		log.Printf("Duplicate event guard enabled with window %s", *cfg.Events.DuplicateWindow)
+		storeOpts = append(storeOpts, storage.WithDuplicateEventGuard(*cfg.Events.DuplicateWindow))
+	}
+	if cfg.Events.IdempotentCreate {
+		storeOpts = append(storeOpts, storage.WithIdempotentCreate())
+	}
+	if cfg.Worker.ExpirySkew > 0 {
+		log.Printf("Expired bookings are cancelled %s late to allow for clock skew", cfg.Worker.ExpirySkew)
+		storeOpts = append(storeOpts, storage.WithExpirySkew(cfg.Worker.ExpirySkew))
+	}
+	store := storage.New(pool, storeOpts...)
+	srv, err := server.New(store, cfg, logger)
+	if err != nil {
+		log.Fatal("Failed to create server:", err)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	log.Printf("Seeding availability metrics")
+	if err := srv.RefreshAvailabilityGauge(ctx); err != nil {
+		log.Printf("Failed to seed availability metrics: %v", err)
+	}
+
+	if cfg.Server.ReadOnly {
+		log.Printf("Read-only mode enabled, writes and expired booking cleanup are disabled")
+	}
 	log.Printf("Starting background worker for expired booking cleanup")
 	go srv.StartBackgroundWorker(ctx)
 
 	log.Printf("Starting HTTP server on port %s", cfg.Server.Port)
 	go func() {
-		if err := srv.Start(cfg.Server.Port); err != nil {
+		if err := srv.Start(cfg.Server.Port); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatal("Server error:", err)
 		}
 	}()
@@ -49,11 +89,20 @@ func main() {
 	log.Printf("Server is running on port %s", cfg.Server.Port)
 	log.Printf("Press Ctrl+C to stop the server")
 
+	// Ctrl+C sends SIGINT; docker stop and Kubernetes send SIGTERM. Both take
+	// the same path, which can be tried with kill -TERM on the process
 	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	sig := <-quit
+
+	log.Printf("Received %s signal, shutting down gracefully...", sig)
 
-	log.Printf("Received interrupt signal, shutting down gracefully...")
+	// Let in-flight requests finish before the pool is closed
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown did not complete: %v", err)
+	}
 	cancel()
 	log.Printf("=== Event Booking Service Stopped ===")
 }
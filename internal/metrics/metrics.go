@@ -0,0 +1,317 @@
+// Package metrics keeps service metrics in memory and writes them in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of WriteText's output.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type collector interface {
+	write(w io.Writer) error
+}
+
+// Registry is the set of metrics exposed together.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// WriteText writes every registered metric, in registration order.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	collectors := slices.Clone(r.collectors)
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// Sample is one labelled value of a vector.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// vector holds the labelled series shared by GaugeVec and CounterVec.
+type vector struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]Sample
+}
+
+func newVector(name, help, kind string, labels []string) vector {
+	return vector{name: name, help: help, kind: kind, labels: labels, series: map[string]Sample{}}
+}
+
+// GaugeVec is a gauge partitioned by labels.
+type GaugeVec struct {
+	vector
+}
+
+// NewGaugeVec registers a gauge with the given label names.
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{vector: newVector(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// Set sets the value of the series with the given label values.
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.checkLabels(labelValues)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.series[seriesKey(labelValues)] = Sample{LabelValues: slices.Clone(labelValues), Value: value}
+}
+
+// Replace swaps all series for samples at once, dropping any series not in
+// samples. Readers never see a partially replaced gauge.
+func (g *GaugeVec) Replace(samples []Sample) {
+	series := make(map[string]Sample, len(samples))
+	for _, s := range samples {
+		g.checkLabels(s.LabelValues)
+		series[seriesKey(s.LabelValues)] = Sample{LabelValues: slices.Clone(s.LabelValues), Value: s.Value}
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.series = series
+}
+
+// CounterVec is a counter partitioned by labels. Counters only go up.
+type CounterVec struct {
+	vector
+}
+
+// NewCounterVec registers a counter with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{vector: newVector(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// Add increases the series with the given label values by delta, creating
+// it at zero first. Adding zero only makes the series visible.
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: %s can't decrease", c.name))
+	}
+	c.checkLabels(labelValues)
+	key := seriesKey(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sample, ok := c.series[key]
+	if !ok {
+		sample.LabelValues = slices.Clone(labelValues)
+	}
+	sample.Value += delta
+	c.series[key] = sample
+}
+
+// Inc increases the series with the given label values by one.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns the value of the series with the given label values and
+// whether that series exists.
+func (v *vector) Value(labelValues ...string) (float64, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[seriesKey(labelValues)]
+	return s.Value, ok
+}
+
+// Len returns the number of series.
+func (v *vector) Len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.series)
+}
+
+func (v *vector) checkLabels(labelValues []string) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+}
+
+func (v *vector) write(w io.Writer) error {
+	v.mu.Lock()
+	samples := make([]Sample, 0, len(v.series))
+	for _, s := range v.series {
+		samples = append(samples, s)
+	}
+	v.mu.Unlock()
+
+	// Sorted so scrapes are stable
+	slices.SortFunc(samples, func(a, b Sample) int {
+		return slices.Compare(a.LabelValues, b.LabelValues)
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.kind)
+	for _, s := range samples {
+		b.WriteString(v.name)
+		writeLabels(&b, v.labels, s.LabelValues)
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(s.Value, 'g', -1, 64))
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// DefaultBuckets are histogram upper bounds in seconds, from a millisecond
+// up to ten seconds.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// HistogramVec is a histogram partitioned by labels. Every series shares the
+// same bucket upper bounds.
+type HistogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	labelValues []string
+	// Per-bucket counts, not cumulative; the last one is +Inf
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogramVec registers a histogram with the given bucket upper bounds,
+// which must be sorted, and label names.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !slices.IsSorted(buckets) {
+		panic(fmt.Sprintf("metrics: %s buckets must be sorted", name))
+	}
+	h := &HistogramVec{name: name, help: help, labels: labels, buckets: slices.Clone(buckets), series: map[string]*histogram{}}
+	r.register(h)
+	return h
+}
+
+// Observe adds value to the series with the given label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", h.name, len(h.labels), len(labelValues)))
+	}
+	key := seriesKey(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{labelValues: slices.Clone(labelValues), counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	i, _ := slices.BinarySearch(h.buckets, value)
+	s.counts[i]++
+	s.sum += value
+	s.count++
+}
+
+// Count returns the number of observations and their sum for the series
+// with the given label values, and whether that series exists.
+func (h *HistogramVec) Count(labelValues ...string) (count uint64, sum float64, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[seriesKey(labelValues)]
+	if !ok {
+		return 0, 0, false
+	}
+	return s.count, s.sum, true
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	h.mu.Lock()
+	series := make([]histogram, 0, len(h.series))
+	for _, s := range h.series {
+		series = append(series, histogram{labelValues: s.labelValues, counts: slices.Clone(s.counts), sum: s.sum, count: s.count})
+	}
+	h.mu.Unlock()
+
+	slices.SortFunc(series, func(a, b histogram) int {
+		return slices.Compare(a.labelValues, b.labelValues)
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s histogram\n", h.name, escapeHelp(h.help), h.name)
+	names := append(slices.Clone(h.labels), "le")
+	for _, s := range series {
+		var cumulative uint64
+		for i, n := range s.counts {
+			cumulative += n
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = strconv.FormatFloat(h.buckets[i], 'g', -1, 64)
+			}
+			b.WriteString(h.name + "_bucket")
+			writeLabels(&b, names, append(slices.Clone(s.labelValues), le))
+			fmt.Fprintf(&b, " %d\n", cumulative)
+		}
+		b.WriteString(h.name + "_sum")
+		writeLabels(&b, h.labels, s.labelValues)
+		b.WriteString(" " + strconv.FormatFloat(s.sum, 'g', -1, 64) + "\n")
+		b.WriteString(h.name + "_count")
+		writeLabels(&b, h.labels, s.labelValues)
+		fmt.Fprintf(&b, " %d\n", s.count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// seriesKey joins label values with a byte that can't appear in UTF-8 text.
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func writeLabels(b *strings.Builder, names, values []string) {
+	if len(names) == 0 {
+		return
+	}
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(b, "%s=\"%s\"", name, escapeLabelValue(values[i]))
+	}
+	b.WriteByte('}')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
+func escapeLabelValue(s string) string { return labelEscaper.Replace(s) }
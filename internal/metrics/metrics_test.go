@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGaugeVec_WriteText(t *testing.T) {
+	r := NewRegistry()
+	g := r.NewGaugeVec("seats", "Seats left.", "event_id")
+	g.Set(5, "2")
+	g.Set(12, "10")
+	g.Set(1, `a"b`)
+
+	var b strings.Builder
+	require.NoError(t, r.WriteText(&b))
+	assert.Equal(t, `# HELP seats Seats left.
+# TYPE seats gauge
+seats{event_id="10"} 12
+seats{event_id="2"} 5
+seats{event_id="a\"b"} 1
+`, b.String())
+}
+
+func TestGaugeVec_Replace(t *testing.T) {
+	g := NewRegistry().NewGaugeVec("seats", "Seats left.", "event_id")
+	g.Set(5, "1")
+	g.Set(7, "2")
+
+	g.Replace([]Sample{{LabelValues: []string{"2"}, Value: 3}, {LabelValues: []string{"3"}, Value: 9}})
+	assert.Equal(t, 2, g.Len())
+
+	_, ok := g.Value("1")
+	assert.False(t, ok)
+	v, ok := g.Value("2")
+	assert.True(t, ok)
+	assert.Equal(t, float64(3), v)
+
+	assert.Panics(t, func() { g.Set(1) })
+}
+
+func TestCounterVec(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("outcomes_total", "Booking outcomes.", "outcome")
+	c.Add(0, "expired")
+	c.Inc("confirmed")
+	c.Add(2, "confirmed")
+
+	v, ok := c.Value("confirmed")
+	assert.True(t, ok)
+	assert.Equal(t, float64(3), v)
+	assert.Panics(t, func() { c.Add(-1, "confirmed") })
+
+	var b strings.Builder
+	require.NoError(t, r.WriteText(&b))
+	assert.Equal(t, `# HELP outcomes_total Booking outcomes.
+# TYPE outcomes_total counter
+outcomes_total{outcome="confirmed"} 3
+outcomes_total{outcome="expired"} 0
+`, b.String())
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	h := r.NewHistogramVec("phase_seconds", "Phase durations.", []float64{0.1, 1}, "phase")
+	h.Observe(0.05, "insert")
+	h.Observe(0.1, "insert")
+	h.Observe(3, "insert")
+	h.Observe(0.5, "acquire")
+
+	count, sum, ok := h.Count("insert")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(3), count)
+	assert.InDelta(t, 3.15, sum, 1e-9)
+	_, _, ok = h.Count("check")
+	assert.False(t, ok)
+	assert.Panics(t, func() { h.Observe(1) })
+	assert.Panics(t, func() { r.NewHistogramVec("unsorted", "", []float64{1, 0.1}) })
+
+	var b strings.Builder
+	require.NoError(t, r.WriteText(&b))
+	assert.Equal(t, `# HELP phase_seconds Phase durations.
+# TYPE phase_seconds histogram
+phase_seconds_bucket{phase="acquire",le="0.1"} 0
+phase_seconds_bucket{phase="acquire",le="1"} 1
+phase_seconds_bucket{phase="acquire",le="+Inf"} 1
+phase_seconds_sum{phase="acquire"} 0.5
+phase_seconds_count{phase="acquire"} 1
+phase_seconds_bucket{phase="insert",le="0.1"} 2
+phase_seconds_bucket{phase="insert",le="1"} 2
+phase_seconds_bucket{phase="insert",le="+Inf"} 3
+phase_seconds_sum{phase="insert"} 3.15
+phase_seconds_count{phase="insert"} 3
+`, b.String())
+}
@@ -0,0 +1,441 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"L3_5/internal/storage"
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// Events per import transaction
+	importBatchSize = 100
+	// Longest accepted NDJSON line; events with bookings inline can be large
+	maxImportLineSize = 16 << 20
+)
+
+// requireAdmin guards admin routes with the configured bearer token. Admin
+// routes are disabled entirely when no token is configured.
+func (s *Server) requireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !s.isAdmin(c) {
+			loggerFrom(c).Warn("Rejected admin request", slog.String("path", c.Path()))
+			return echo.NewHTTPError(http.StatusUnauthorized, "Admin authentication required")
+		}
+		return next(c)
+	}
+}
+
+func (s *Server) isAdmin(c echo.Context) bool {
+	if s.cfg.Admin.Token == "" {
+		return false
+	}
+	token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Admin.Token)) == 1
+}
+
+const dbTimeoutContextKey = "db_timeout"
+
+// dbTimeout lets admins cap how long the statements of their request's
+// transactions may run, via an X-DB-Timeout duration such as "250ms". The
+// header is ignored for everyone else.
+func (s *Server) dbTimeout(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		raw := c.Request().Header.Get("X-DB-Timeout")
+		if raw == "" || !s.isAdmin(c) {
+			return next(c)
+		}
+		timeout, err := time.ParseDuration(raw)
+		if err != nil || timeout <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "X-DB-Timeout must be a positive duration")
+		}
+		loggerFrom(c).Info("Applying statement timeout", slog.Duration("timeout", timeout))
+		c.Set(dbTimeoutContextKey, timeout)
+		return next(c)
+	}
+}
+
+// dbContext is the context handlers pass to storage. Like the plain
+// background context it isn't cancelled with the request, but it carries
+// the statement timeout set by dbTimeout.
+func dbContext(c echo.Context) context.Context {
+	if timeout, ok := c.Get(dbTimeoutContextKey).(time.Duration); ok {
+		return storage.WithStatementTimeout(context.Background(), timeout)
+	}
+	return context.Background()
+}
+
+func (s *Server) getConfig(c echo.Context) error {
+	loggerFrom(c).Info("Returning effective config", slog.String("op", "server.getConfig"))
+	return c.JSON(http.StatusOK, s.cfg.Redacted())
+}
+
+// getWorker reports the state of the background worker: how its cleanup
+// runs are going and how often each of its tasks has failed.
+func (s *Server) getWorker(c echo.Context) error {
+	type taskErrors struct {
+		Task   string `json:"task" xml:"task,attr"`
+		Errors int64  `json:"errors" xml:",chardata"`
+	}
+	response := struct {
+		ReadOnly            bool         `json:"read_only" xml:"read_only"`
+		LastSuccess         *time.Time   `json:"last_success" xml:"last_success,omitempty"`
+		ConsecutiveFailures int64        `json:"consecutive_failures" xml:"consecutive_failures"`
+		LastError           string       `json:"last_error,omitempty" xml:"last_error,omitempty"`
+		LastErrorAt         *time.Time   `json:"last_error_at,omitempty" xml:"last_error_at,omitempty"`
+		Errors              []taskErrors `json:"errors" xml:"errors>task"`
+	}{
+		ReadOnly:            s.readOnly,
+		ConsecutiveFailures: s.cleanupFailures.Load(),
+		Errors:              make([]taskErrors, 0, len(workerTasks)),
+	}
+	if nanos := s.lastCleanup.Load(); nanos != 0 {
+		last := time.Unix(0, nanos).UTC()
+		response.LastSuccess = &last
+	}
+	if last := s.lastCleanupError.Load(); last != nil {
+		response.LastError = last.Message
+		response.LastErrorAt = &last.At
+	}
+	for _, task := range workerTasks {
+		count, _ := s.metrics.workerErrors.Value(task)
+		response.Errors = append(response.Errors, taskErrors{Task: task, Errors: int64(count)})
+	}
+
+	loggerFrom(c).Info("Returning worker state", slog.String("op", "server.getWorker"))
+	return render(c, http.StatusOK, "worker", response)
+}
+
+func (s *Server) getExpiredPending(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getExpiredPending"))
+
+	ctx := dbContext(c)
+	bookings, err := s.storage.GetExpiredPending(ctx)
+	if err != nil {
+		logger.Error("Failed to get expired pending bookings", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get expired bookings")
+	}
+
+	response := struct {
+		Bookings []models.ExpiredBooking `json:"bookings" xml:"bookings>booking"`
+		Count    int                     `json:"count" xml:"count"`
+	}{
+		Bookings: bookings,
+		Count:    len(bookings),
+	}
+
+	logger.Info("Returned expired pending bookings", slog.Int("count", len(bookings)))
+	return render(c, http.StatusOK, "expired_bookings", response)
+}
+
+// getAdminEvents lists events for auditing, past ones included unless
+// include_past says otherwise. from_created and to_created narrow the list
+// to events created in that window, whatever their own date.
+func (s *Server) getAdminEvents(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getAdminEvents"))
+
+	filter, err := parseEventFilter(c)
+	if err != nil {
+		logger.Warn("Invalid filter parameters", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if c.QueryParam("include_past") == "" {
+		filter.IncludePast = true
+	}
+
+	if raw := c.QueryParam("from_created"); raw != "" {
+		if filter.CreatedFrom, _, err = parseTimeParam(raw); err != nil {
+			logger.Warn("Invalid from_created parameter", slog.String("from_created", raw))
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid from_created")
+		}
+	}
+	if raw := c.QueryParam("to_created"); raw != "" {
+		var dateOnly bool
+		if filter.CreatedTo, dateOnly, err = parseTimeParam(raw); err != nil {
+			logger.Warn("Invalid to_created parameter", slog.String("to_created", raw))
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid to_created")
+		}
+		// A bare date includes the whole day
+		if dateOnly {
+			filter.CreatedTo = filter.CreatedTo.AddDate(0, 0, 1).Add(-time.Microsecond)
+		}
+	}
+	if !filter.CreatedFrom.IsZero() && !filter.CreatedTo.IsZero() && filter.CreatedTo.Before(filter.CreatedFrom) {
+		return echo.NewHTTPError(http.StatusBadRequest, "to_created must not be before from_created")
+	}
+
+	return s.listEvents(c, logger.With(
+		slog.Time("from_created", filter.CreatedFrom),
+		slog.Time("to_created", filter.CreatedTo)), filter)
+}
+
+func (s *Server) deleteEvents(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.deleteEvents"))
+
+	// Refuse to guess a cutoff; an unbounded purge must be asked for explicitly
+	raw := c.QueryParam("before")
+	if raw == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "before is required")
+	}
+	before, _, err := parseTimeParam(raw)
+	if err != nil {
+		logger.Warn("Invalid before parameter", slog.String("before", raw))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid before")
+	}
+
+	status := c.QueryParam("status")
+	switch status {
+	case "":
+		status = storage.EventStatusCompleted
+	case storage.EventStatusCompleted, storage.EventStatusAny:
+	default:
+		logger.Warn("Invalid status parameter", slog.String("status", status))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid status")
+	}
+
+	logger.Info("Deleting events", slog.Time("before", before), slog.String("status", status))
+
+	ctx := dbContext(c)
+	deleted, err := s.storage.DeleteEventsBefore(ctx, before, status)
+	if err != nil {
+		logger.Error("Failed to delete events", slog.Int64("deleted", deleted), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to delete events")
+	}
+
+	response := struct {
+		Deleted int64 `json:"deleted" xml:"deleted"`
+	}{
+		Deleted: deleted,
+	}
+
+	logger.Info("Deleted events", slog.Int64("deleted", deleted))
+	return render(c, http.StatusOK, "deleted_events", response)
+}
+
+// recomputeSeats repairs an event's confirmed seat counter from its bookings.
+func (s *Server) recomputeSeats(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.recomputeSeats"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+
+	ctx := dbContext(c)
+	recount, err := s.storage.RecomputeConfirmedSeats(ctx, eventID)
+	if err != nil {
+		if errors.Is(err, storage.ErrEventNotFound) {
+			logger.Warn("Event not found", slog.Int("event_id", eventID))
+			return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+		}
+		logger.Error("Failed to recompute confirmed seats", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to recompute confirmed seats")
+	}
+
+	logger.Info("Recomputed confirmed seats",
+		slog.Int("event_id", eventID), slog.Int64("before", recount.Before), slog.Int64("after", recount.After))
+	if recount.Before != recount.After {
+		s.availability.publish(eventID)
+	}
+	return render(c, http.StatusOK, "recount", recount)
+}
+
+// recomputeAllSeats repairs the confirmed seat counter of every event and
+// reports the ones that had drifted.
+func (s *Server) recomputeAllSeats(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.recomputeAllSeats"))
+
+	ctx := dbContext(c)
+	recounts, err := s.storage.RecomputeAllConfirmedSeats(ctx)
+	if err != nil {
+		logger.Error("Failed to recompute confirmed seats", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to recompute confirmed seats")
+	}
+	for _, r := range recounts {
+		logger.Info("Repaired confirmed seats",
+			slog.Int("event_id", r.EventID), slog.Int64("before", r.Before), slog.Int64("after", r.After))
+		s.availability.publish(r.EventID)
+	}
+
+	response := struct {
+		Repaired []models.SeatRecount `json:"repaired" xml:"repaired>recount"`
+	}{
+		Repaired: recounts,
+	}
+	if response.Repaired == nil {
+		response.Repaired = []models.SeatRecount{}
+	}
+
+	logger.Info("Recomputed confirmed seats", slog.Int("repaired", len(recounts)))
+	return render(c, http.StatusOK, "recounts", response)
+}
+
+// verifyIntegrity reports events confirmed beyond capacity and active
+// bookings of missing events. It only reads; recompute repairs counters.
+func (s *Server) verifyIntegrity(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.verifyIntegrity"))
+
+	ctx := dbContext(c)
+	report, err := s.storage.VerifyIntegrity(ctx)
+	if err != nil {
+		logger.Error("Failed to verify integrity", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to verify integrity")
+	}
+	for _, v := range report.Violations {
+		logger.Warn("Integrity violation", slog.String("check", v.Check), slog.String("detail", v.Detail))
+	}
+
+	logger.Info("Verified integrity",
+		slog.Int("events_checked", report.EventsChecked), slog.Int("violations", len(report.Violations)))
+	return render(c, http.StatusOK, "integrity", report)
+}
+
+func (s *Server) exportEvents(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.exportEvents"))
+
+	includeBookings := false
+	if raw := c.QueryParam("include_bookings"); raw != "" {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			logger.Warn("Invalid include_bookings parameter", slog.String("include_bookings", raw))
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid include_bookings")
+		}
+		includeBookings = parsed
+	}
+
+	logger.Info("Exporting events", slog.Bool("include_bookings", includeBookings))
+
+	c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	c.Response().WriteHeader(http.StatusOK)
+
+	// The request context stops the cursor when the client goes away mid-download
+	enc := json.NewEncoder(c.Response())
+	count := 0
+	err := s.storage.ExportEvents(c.Request().Context(), includeBookings, func(event models.EventExport) error {
+		if err := enc.Encode(event); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	if err != nil {
+		// The status is already sent, so a failure can only show as a truncated body
+		logger.Error("Event export aborted", slog.Int("events", count), slog.Any("error", err))
+		return nil
+	}
+
+	logger.Info("Exported events", slog.Int("events", count))
+	return nil
+}
+
+func (s *Server) importEvents(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.importEvents"))
+
+	mode := c.QueryParam("mode")
+	switch mode {
+	case "":
+		mode = storage.ImportModeSkip
+	case storage.ImportModeSkip, storage.ImportModeUpsert:
+	default:
+		logger.Warn("Invalid mode parameter", slog.String("mode", mode))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid mode")
+	}
+
+	logger.Info("Importing events", slog.String("mode", mode))
+
+	ctx := dbContext(c)
+	var total models.ImportResult
+	batch := make([]models.EventExport, 0, importBatchSize)
+
+	// Batches are committed as they fill up, so a failure leaves earlier ones imported
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result, err := s.storage.ImportEvents(ctx, batch, mode)
+		if err != nil {
+			return err
+		}
+		total.Inserted += result.Inserted
+		total.Updated += result.Updated
+		total.Skipped += result.Skipped
+		batch = batch[:0]
+		return nil
+	}
+	importFailed := func(err error) error {
+		logger.Error("Event import failed", slog.Any("imported", total), slog.Any("error", err))
+		if errors.Is(err, storage.ErrSeatsBelowTaken) {
+			return echo.NewHTTPError(http.StatusConflict, fmt.Sprintf(
+				"total_seats is below the number of confirmed seats (%d events imported before the failing batch)",
+				total.Inserted+total.Updated))
+		}
+		if httpErr := constraintHTTPError(err); httpErr != nil {
+			httpErr.Message = fmt.Sprintf("%s (%d events imported before the failing batch)",
+				httpErr.Message, total.Inserted+total.Updated)
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to import events")
+	}
+
+	scanner := bufio.NewScanner(c.Request().Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+
+		if !json.Valid(raw) {
+			logger.Warn("Invalid import line", slog.Int("line", line))
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("line %d: invalid event JSON", line))
+		}
+		// Imported events are held to the same rules as created ones
+		event, errs := s.decodeEvent(raw, true)
+		if errs != nil {
+			logger.Warn("Invalid imported event", slog.Int("line", line), slog.Any("error", errs))
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, fmt.Sprintf("line %d: %s", line, errs.Error()))
+		}
+
+		batch = append(batch, event)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return importFailed(err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		logger.Warn("Failed to read import body", slog.Int("line", line), slog.Any("error", err))
+		if errors.Is(err, bufio.ErrTooLong) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, fmt.Sprintf("line %d is too long", line+1))
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "Failed to read request body")
+	}
+	if err := flush(); err != nil {
+		return importFailed(err)
+	}
+
+	logger.Info("Imported events",
+		slog.Int("inserted", total.Inserted),
+		slog.Int("updated", total.Updated),
+		slog.Int("skipped", total.Skipped))
+	return render(c, http.StatusOK, "import_result", total)
+}
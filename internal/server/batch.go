@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"L3_5/internal/storage"
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Most events accepted by one batch creation
+const maxBatchEvents = 100
+
+// BatchItemErrors lists what is wrong with one event of a batch, by its
+// position in the request.
+type BatchItemErrors struct {
+	Index  int          `json:"index"`
+	Errors []FieldError `json:"errors"`
+}
+
+// decodeEvent decodes and validates one event of a batch or an import,
+// collecting every problem so one bad field doesn't hide the others. Restored
+// events come from a backup: they must keep their ID and may be in the past.
+func (s *Server) decodeEvent(raw []byte, restore bool) (models.EventExport, ValidationErrors) {
+	var event models.EventExport
+	if err := json.Unmarshal(raw, &event); err != nil {
+		fe := FieldError{Field: "event", Message: "invalid event JSON"}
+		switch {
+		case errors.Is(err, models.ErrDateOutOfRange):
+			fe = FieldError{Field: "date", Message: errors.Unwrap(err).Error()}
+		case errors.Is(err, models.ErrInvalidTimezone):
+			fe = FieldError{Field: "timezone", Message: errors.Unwrap(err).Error()}
+		}
+		return event, ValidationErrors{fe}
+	}
+
+	errs := s.eventErrors(&event.Event, restore)
+	if restore && event.ID <= 0 {
+		errs.add("id", "id must be positive")
+	}
+	return event, errs
+}
+
+// createEventBatch creates several events at once after validating all of
+// them; any invalid event rejects the whole batch, and a failure to store any
+// of them stores none. With validate_only set it
+// only reports whether the batch would be accepted, inserting nothing.
+func (s *Server) createEventBatch(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.createEventBatch"))
+
+	validateOnly := false
+	if raw := c.QueryParam("validate_only"); raw != "" {
+		var err error
+		if validateOnly, err = strconv.ParseBool(raw); err != nil {
+			logger.Warn("Invalid validate_only parameter", slog.String("validate_only", raw))
+			return echo.NewHTTPError(http.StatusBadRequest, "validate_only must be a boolean")
+		}
+	}
+
+	var request struct {
+		Events []json.RawMessage `json:"events"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Failed to bind batch request data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if len(request.Events) == 0 {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "events must not be empty")
+	}
+	if len(request.Events) > maxBatchEvents {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, fmt.Sprintf("at most %d events are allowed", maxBatchEvents))
+	}
+
+	logger.Info("Validating event batch", slog.Int("count", len(request.Events)), slog.Bool("validate_only", validateOnly))
+
+	// Every event is checked, so one bad item doesn't hide the problems of the others
+	events := make([]models.Event, len(request.Events))
+	invalid := []BatchItemErrors{}
+	for i, raw := range request.Events {
+		event, errs := s.decodeEvent(raw, false)
+		if errs != nil {
+			invalid = append(invalid, BatchItemErrors{Index: i, Errors: errs})
+		}
+		events[i] = event.Event
+	}
+	report := struct {
+		Valid  bool              `json:"valid" xml:"valid"`
+		Count  int               `json:"count" xml:"count"`
+		Errors []BatchItemErrors `json:"errors" xml:"errors>item"`
+	}{
+		Valid:  len(invalid) == 0,
+		Count:  len(events),
+		Errors: invalid,
+	}
+
+	if validateOnly {
+		logger.Info("Validated event batch", slog.Int("count", len(events)), slog.Int("invalid", len(invalid)))
+		return render(c, http.StatusOK, "batch_validation", report)
+	}
+	if !report.Valid {
+		logger.Warn("Event batch validation failed", slog.Int("invalid", len(invalid)))
+		return render(c, http.StatusUnprocessableEntity, "batch_validation", report)
+	}
+
+	ctx := dbContext(c)
+	if err := s.storage.CreateEvents(ctx, events); err != nil {
+		logger.Error("Failed to create event batch", slog.Any("error", err))
+
+		// The batch is created in one transaction, so none of it was kept
+		var batchErr *storage.BatchEventError
+		if !errors.As(err, &batchErr) {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create events")
+		}
+		if errors.Is(err, storage.ErrDuplicateEvent) {
+			return echo.NewHTTPError(http.StatusConflict,
+				fmt.Sprintf("event %d: event with the same name and date already exists", batchErr.Index))
+		}
+		if httpErr := constraintHTTPError(err); httpErr != nil {
+			httpErr.Message = fmt.Sprintf("event %d: %s", batchErr.Index, httpErr.Message)
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create events")
+	}
+
+	response := struct {
+		Events []models.Event `json:"events" xml:"event"`
+	}{
+		Events: events,
+	}
+
+	logger.Info("Successfully created event batch", slog.Int("count", len(events)))
+	return render(c, http.StatusCreated, "events", response)
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"L3_5/internal/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+// cancelBooking lets the holder of a pending booking give it up. The confirm
+// token issued at booking time proves they hold it.
+func (s *Server) cancelBooking(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.cancelBooking"))
+
+	reference := c.Param("ref")
+
+	var request struct {
+		ConfirmToken string `json:"confirm_token"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Failed to bind cancellation request data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if request.ConfirmToken == "" {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "confirm_token is required")
+	}
+
+	ctx, err := ifMatchContext(c)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Cancelling booking", slog.String("reference", reference))
+
+	booking, err := s.storage.CancelBooking(ctx, reference, request.ConfirmToken)
+	if err != nil {
+		logger.Warn("Failed to cancel booking", slog.String("reference", reference), slog.Any("error", err))
+		switch {
+		case errors.Is(err, storage.ErrBookingNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found")
+		case errors.Is(err, storage.ErrInvalidToken):
+			return echo.NewHTTPError(http.StatusForbidden, "Invalid confirm token")
+		case errors.Is(err, storage.ErrVersionMismatch):
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "Booking was changed since it was read")
+		case errors.Is(err, storage.ErrNotPending):
+			return echo.NewHTTPError(http.StatusConflict, "Only pending bookings can be cancelled")
+		case errors.Is(err, storage.ErrCancellationClosed):
+			return echo.NewHTTPError(http.StatusConflict, "Cancellation window for this event has closed")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to cancel booking")
+	}
+
+	logger.Info("Successfully cancelled booking", slog.Int("booking_id", booking.ID))
+	s.availability.publish(booking.EventID)
+	s.metrics.bookingOutcomes.Inc(outcomeCancelledByUser)
+	setBookingETag(c, booking)
+	return render(c, http.StatusOK, "booking", booking)
+}
+
+// refundBooking cancels a confirmed booking and frees its seats. Only the
+// organizer of the booking's event may refund it.
+func (s *Server) refundBooking(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.refundBooking"))
+
+	reference := c.Param("ref")
+	organizerID := organizerFrom(c)
+
+	ctx := dbContext(c)
+	booking, err := s.storage.GetBookingByReference(ctx, reference)
+	if err != nil {
+		if errors.Is(err, storage.ErrBookingNotFound) {
+			logger.Warn("Booking not found", slog.String("reference", reference))
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found")
+		}
+		logger.Error("Failed to get booking", slog.String("reference", reference), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to refund booking")
+	}
+
+	event, err := s.storage.GetEvent(ctx, booking.EventID)
+	if err != nil {
+		logger.Error("Failed to get event", slog.Int("event_id", booking.EventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to refund booking")
+	}
+	if event.OrganizerID == nil || *event.OrganizerID != organizerID {
+		logger.Warn("Organizer does not own the event",
+			slog.Int("organizer_id", organizerID), slog.Int("event_id", event.ID))
+		return echo.NewHTTPError(http.StatusForbidden, "Booking belongs to another organizer's event")
+	}
+
+	logger.Info("Refunding booking", slog.Int("booking_id", booking.ID), slog.Int("organizer_id", organizerID))
+
+	booking, err = s.storage.RefundBooking(ctx, reference)
+	if err != nil {
+		logger.Warn("Failed to refund booking", slog.String("reference", reference), slog.Any("error", err))
+		switch {
+		case errors.Is(err, storage.ErrBookingNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found")
+		case errors.Is(err, storage.ErrNotConfirmed):
+			return echo.NewHTTPError(http.StatusConflict, "Only confirmed bookings can be refunded")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to refund booking")
+	}
+
+	logger.Info("Successfully refunded booking", slog.Int("booking_id", booking.ID))
+	s.availability.publish(booking.EventID)
+	return render(c, http.StatusOK, "booking", booking)
+}
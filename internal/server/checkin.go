@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"L3_5/internal/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+// checkIn marks a confirmed booking as attended. Only the organizer of the
+// booking's event may check it in.
+func (s *Server) checkIn(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.checkIn"))
+
+	reference := c.Param("ref")
+	organizerID := organizerFrom(c)
+
+	ctx := dbContext(c)
+	booking, err := s.storage.GetBookingByReference(ctx, reference)
+	if err != nil {
+		if errors.Is(err, storage.ErrBookingNotFound) {
+			logger.Warn("Booking not found", slog.String("reference", reference))
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found")
+		}
+		logger.Error("Failed to get booking", slog.String("reference", reference), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check in booking")
+	}
+
+	event, err := s.storage.GetEvent(ctx, booking.EventID)
+	if err != nil {
+		logger.Error("Failed to get event", slog.Int("event_id", booking.EventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check in booking")
+	}
+	if event.OrganizerID == nil || *event.OrganizerID != organizerID {
+		logger.Warn("Organizer does not own the event",
+			slog.Int("organizer_id", organizerID), slog.Int("event_id", event.ID))
+		return echo.NewHTTPError(http.StatusForbidden, "Booking belongs to another organizer's event")
+	}
+
+	logger.Info("Checking in booking", slog.Int("booking_id", booking.ID), slog.Int("organizer_id", organizerID))
+
+	booking, err = s.storage.CheckIn(ctx, reference)
+	if err != nil {
+		logger.Warn("Failed to check in booking", slog.String("reference", reference), slog.Any("error", err))
+		switch {
+		case errors.Is(err, storage.ErrBookingNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found")
+		case errors.Is(err, storage.ErrNotConfirmed):
+			return echo.NewHTTPError(http.StatusConflict, "Only confirmed bookings can be checked in")
+		case errors.Is(err, storage.ErrCheckedIn):
+			return echo.NewHTTPError(http.StatusConflict, "Booking is already checked in")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to check in booking")
+	}
+
+	logger.Info("Successfully checked in booking", slog.Int("booking_id", booking.ID))
+	return render(c, http.StatusOK, "booking", booking)
+}
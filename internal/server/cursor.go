@@ -0,0 +1,57 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"L3_5/models"
+)
+
+// Cursors are opaque to clients: base64url("<unix nanos>:<id>").
+func encodeEventCursor(cursor models.EventCursor) string {
+	raw := strconv.FormatInt(cursor.Date.UnixNano(), 10) + ":" + strconv.Itoa(cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeEventCursor(token string) (models.EventCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return models.EventCursor{}, fmt.Errorf("decode cursor: %w", err)
+	}
+
+	nanos, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return models.EventCursor{}, fmt.Errorf("malformed cursor")
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return models.EventCursor{}, fmt.Errorf("malformed cursor date: %w", err)
+	}
+	eventID, err := strconv.Atoi(id)
+	if err != nil {
+		return models.EventCursor{}, fmt.Errorf("malformed cursor id: %w", err)
+	}
+
+	return models.EventCursor{Date: time.Unix(0, n).UTC(), ID: eventID}, nil
+}
+
+// Booking cursors are base64url("<booking id>"); pages are ordered by ID.
+func encodeBookingCursor(afterID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(afterID)))
+}
+
+func decodeBookingCursor(token string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("decode cursor: %w", err)
+	}
+	afterID, err := strconv.Atoi(string(raw))
+	if err != nil || afterID <= 0 {
+		return 0, fmt.Errorf("malformed cursor id")
+	}
+	return afterID, nil
+}
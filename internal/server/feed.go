@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"L3_5/internal/storage"
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+const defaultFeedInterval = 5 * time.Second
+
+// AvailabilityUpdate is one push of the availability feed. Countdowns are
+// whole seconds computed from server time and stop at zero. The count is
+// left out, and LowAvailability set, where the event listings hide it.
+type AvailabilityUpdate struct {
+	EventID         int       `json:"event_id"`
+	AvailableSeats  *int64    `json:"available_seats,omitempty"`
+	LowAvailability bool      `json:"low_availability,omitempty"`
+	ServerTime      time.Time `json:"server_time"`
+	StartsIn        int64     `json:"starts_in_seconds"`
+	// Events have no separate booking cutoff, so booking closes at the start
+	BookingClosesIn int64 `json:"booking_closes_in_seconds"`
+}
+
+// newAvailabilityUpdate builds an update from an event as withAvailableSeats
+// returns it, so the feeds hide low counts exactly as the listings do.
+func newAvailabilityUpdate(item EventWithAvailableSeats, now time.Time) AvailabilityUpdate {
+	startsIn := int64(max(item.Date.Sub(now), 0) / time.Second)
+	update := AvailabilityUpdate{
+		EventID:         item.ID,
+		LowAvailability: item.LowAvailability,
+		ServerTime:      now.UTC(),
+		StartsIn:        startsIn,
+		BookingClosesIn: startsIn,
+	}
+	if item.SeatCounts != nil {
+		available := item.Available
+		update.AvailableSeats = &available
+	}
+	return update
+}
+
+// streamAvailability pushes an AvailabilityUpdate as a server-sent event
+// right away and then every feedInterval until the client disconnects.
+func (s *Server) streamAvailability(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.streamAvailability"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+
+	// The stream lives as long as the client, so follow the request context
+	ctx := c.Request().Context()
+	if _, err := s.storage.GetEvent(ctx, eventID); err != nil {
+		logger.Warn("Failed to get event", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+	}
+
+	logger.Info("Streaming availability", slog.Int("event_id", eventID))
+	exact := s.isAdmin(c)
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(s.feedInterval)
+	defer ticker.Stop()
+
+	pushes := 0
+	for {
+		// Reload the event each time so a reschedule moves the countdown too
+		event, err := s.storage.GetEvent(ctx, eventID)
+		if errors.Is(err, storage.ErrEventNotFound) {
+			fmt.Fprint(res, "event: deleted\ndata: {}\n\n")
+			res.Flush()
+			logger.Info("Event deleted, closing availability stream", slog.Int("event_id", eventID))
+			return nil
+		}
+		var items []EventWithAvailableSeats
+		if err == nil {
+			items, err = s.withAvailableSeats(ctx, []models.Event{*event}, exact)
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error("Failed to load availability", slog.Int("event_id", eventID), slog.Any("error", err))
+			}
+			return nil
+		}
+
+		data, err := json.Marshal(newAvailabilityUpdate(items[0], time.Now()))
+		if err != nil {
+			logger.Error("Failed to encode availability update", slog.Any("error", err))
+			return nil
+		}
+		if _, err := fmt.Fprintf(res, "event: availability\ndata: %s\n\n", data); err != nil {
+			return nil
+		}
+		res.Flush()
+		pushes++
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Availability stream closed", slog.Int("event_id", eventID), slog.Int("pushes", pushes))
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"L3_5/internal/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// How long each heartbeat keeps a hold alive
+	heartbeatStep = 2 * time.Minute
+	// Most heartbeats can push a hold past its own end
+	maxHeartbeatPush = 15 * time.Minute
+)
+
+// heartbeatBooking keeps the hold of a pending booking from expiring while
+// its holder is still checking out. Unlike an organizer's extension it only
+// keeps the hold a short while past the last heartbeat, so a holder who
+// leaves loses it soon after.
+func (s *Server) heartbeatBooking(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.heartbeatBooking"))
+
+	reference := c.Param("ref")
+
+	var request struct {
+		ConfirmToken string `json:"confirm_token"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Failed to bind heartbeat request data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if request.ConfirmToken == "" {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "confirm_token is required")
+	}
+
+	ctx := dbContext(c)
+	expiresAt, err := s.storage.HeartbeatBooking(ctx, reference, request.ConfirmToken, heartbeatStep, maxHeartbeatPush)
+	if err != nil {
+		logger.Warn("Failed to record heartbeat", slog.String("reference", reference), slog.Any("error", err))
+		switch {
+		case errors.Is(err, storage.ErrBookingNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found")
+		case errors.Is(err, storage.ErrInvalidToken):
+			return echo.NewHTTPError(http.StatusForbidden, "Invalid confirm token")
+		case errors.Is(err, storage.ErrNotPending):
+			return echo.NewHTTPError(http.StatusConflict, "Only pending bookings can be kept alive")
+		case errors.Is(err, storage.ErrBookingExpired):
+			return echo.NewHTTPError(http.StatusGone, "Booking hold has expired, please book again")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to record heartbeat")
+	}
+
+	response := struct {
+		Reference string    `json:"reference" xml:"reference"`
+		ExpiresAt time.Time `json:"expires_at" xml:"expires_at"`
+	}{
+		Reference: reference,
+		ExpiresAt: expiresAt,
+	}
+
+	logger.Info("Recorded heartbeat", slog.String("reference", reference), slog.Time("expires_at", expiresAt))
+	return render(c, http.StatusOK, "booking_heartbeat", response)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/labstack/echo/v4"
+)
+
+const loggerContextKey = "logger"
+
+type requestIDKey struct{}
+
+// requestContext returns a context carrying the request ID for work that
+// outlives the request, such as notifications. It is not cancelled with the
+// request.
+func requestContext(c echo.Context) context.Context {
+	return context.WithValue(context.Background(), requestIDKey{}, c.Response().Header().Get(echo.HeaderXRequestID))
+}
+
+// requestIDFrom returns the request ID stored by requestContext, if any.
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// requestLogger stores a child logger carrying the request ID and client IP
+// on the echo context. It must run after middleware.RequestID.
+func (s *Server) requestLogger(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		logger := s.logger.With(
+			slog.String("request_id", c.Response().Header().Get(echo.HeaderXRequestID)),
+			slog.String("real_ip", c.RealIP()),
+		)
+		c.Set(loggerContextKey, logger)
+		return next(c)
+	}
+}
+
+// loggerFrom returns the request-scoped logger, falling back to the default
+// logger for contexts that did not pass through requestLogger.
+func loggerFrom(c echo.Context) *slog.Logger {
+	if logger, ok := c.Get(loggerContextKey).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
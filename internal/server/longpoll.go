@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"L3_5/internal/storage"
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// availabilityBus records which events had their availability change.
+// Every publish bumps a sequence number, which long-poll clients hold on to
+// as their token, and wakes everyone waiting.
+type availabilityBus struct {
+	mu  sync.Mutex
+	seq uint64
+	// Sequence number of the latest change per event
+	changedAt map[int]uint64
+	// Closed and replaced on every publish
+	wake chan struct{}
+}
+
+func newAvailabilityBus() *availabilityBus {
+	return &availabilityBus{
+		changedAt: make(map[int]uint64),
+		wake:      make(chan struct{}),
+	}
+}
+
+func (b *availabilityBus) publish(eventIDs ...int) {
+	if len(eventIDs) == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	for _, id := range eventIDs {
+		b.changedAt[id] = b.seq
+	}
+	close(b.wake)
+	b.wake = make(chan struct{})
+}
+
+func (b *availabilityBus) token() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.seq
+}
+
+// changedSince returns those of eventIDs that changed after since, the
+// current token and a channel closed on the next publish. A token from
+// ahead of the bus was handed out before a restart, so everything counts as
+// changed and the client resyncs.
+func (b *availabilityBus) changedSince(eventIDs []int, since uint64) ([]int, uint64, <-chan struct{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var changed []int
+	for _, id := range eventIDs {
+		if since > b.seq || b.changedAt[id] > since {
+			changed = append(changed, id)
+		}
+	}
+	return changed, b.seq, b.wake
+}
+
+// parseIDList parses a comma-separated list of event IDs, dropping repeats.
+func parseIDList(raw string) ([]int, error) {
+	var ids []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(raw, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid event ID %q", part)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// longPollAvailability waits until one of the listed events changes
+// availability after the since token, or until longPollTimeout passes, and
+// returns the changed events with a token for the next call. Without a
+// token it waits for the next change.
+func (s *Server) longPollAvailability(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.longPollAvailability"))
+
+	rawIDs := c.QueryParam("ids")
+	if rawIDs == "" {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "ids must not be empty")
+	}
+	ids, err := parseIDList(rawIDs)
+	if err != nil {
+		logger.Warn("Invalid ids parameter", slog.String("ids", rawIDs))
+		return echo.NewHTTPError(http.StatusBadRequest, "ids must be a comma-separated list of event IDs")
+	}
+	if len(ids) > maxBatchIDs {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, fmt.Sprintf("at most %d ids are allowed", maxBatchIDs))
+	}
+
+	since := s.availability.token()
+	if raw := c.QueryParam("since"); raw != "" {
+		since, err = strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			logger.Warn("Invalid since parameter", slog.String("since", raw))
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid since token")
+		}
+	}
+
+	logger.Info("Long-polling availability", slog.Any("event_ids", ids), slog.Uint64("since", since))
+
+	// Follow the client, but answer by the deadline even if nothing changed
+	ctx := c.Request().Context()
+	waitCtx, cancel := context.WithTimeout(ctx, s.longPollTimeout)
+	defer cancel()
+
+	for {
+		changed, token, wake := s.availability.changedSince(ids, since)
+		if len(changed) > 0 {
+			updates, err := s.availabilityUpdates(ctx, changed, s.isAdmin(c))
+			if err != nil {
+				logger.Error("Failed to load availability", slog.Any("error", err))
+				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to load availability")
+			}
+			logger.Info("Returning availability changes", slog.Int("count", len(updates)))
+			return renderLongPoll(c, updates, token)
+		}
+
+		select {
+		case <-wake:
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return nil
+			}
+			logger.Info("Long-poll timed out without changes")
+			return renderLongPoll(c, []AvailabilityUpdate{}, token)
+		}
+	}
+}
+
+// availabilityUpdates loads the current availability of eventIDs. Events
+// deleted in the meantime are left out, and low counts hidden unless exact.
+func (s *Server) availabilityUpdates(ctx context.Context, eventIDs []int, exact bool) ([]AvailabilityUpdate, error) {
+	events := make([]models.Event, 0, len(eventIDs))
+	for _, id := range eventIDs {
+		event, err := s.storage.GetEvent(ctx, id)
+		if errors.Is(err, storage.ErrEventNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *event)
+	}
+
+	items, err := s.withAvailableSeats(ctx, events, exact)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	updates := make([]AvailabilityUpdate, 0, len(items))
+	for _, item := range items {
+		updates = append(updates, newAvailabilityUpdate(item, now))
+	}
+	return updates, nil
+}
+
+func renderLongPoll(c echo.Context, updates []AvailabilityUpdate, token uint64) error {
+	response := struct {
+		Changes []AvailabilityUpdate `json:"changes" xml:"changes>change"`
+		Token   string               `json:"token" xml:"token"`
+	}{
+		Changes: updates,
+		Token:   strconv.FormatUint(token, 10),
+	}
+	return render(c, http.StatusOK, "availability", response)
+}
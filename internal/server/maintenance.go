@@ -0,0 +1,73 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// maintenanceRetryAfter is the Retry-After hint, in seconds, sent while writes
+// are blocked.
+const maintenanceRetryAfter = 120
+
+// SetMaintenanceMode toggles rejecting writes with 503 while reads keep working.
+func (s *Server) SetMaintenanceMode(enabled bool) {
+	s.maintenance.Store(enabled)
+}
+
+// MaintenanceMode reports whether writes are currently blocked.
+func (s *Server) MaintenanceMode() bool {
+	return s.maintenance.Load()
+}
+
+// rejectWritesInMaintenance answers every write with 503 while maintenance mode
+// is on. Admin routes stay open so operators can turn it back off, and the
+// status lookup is a POST only because it takes a body.
+func (s *Server) rejectWritesInMaintenance(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !s.maintenance.Load() {
+			return next(c)
+		}
+		switch c.Request().Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return next(c)
+		}
+		if strings.HasPrefix(c.Path(), "/admin/") || c.Path() == "/bookings/status" {
+			return next(c)
+		}
+
+		loggerFrom(c).Info("Rejected write during maintenance",
+			slog.String("method", c.Request().Method),
+			slog.String("path", c.Path()))
+		c.Response().Header().Set("Retry-After", strconv.Itoa(maintenanceRetryAfter))
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Service is in maintenance mode, writes are temporarily disabled")
+	}
+}
+
+func (s *Server) setMaintenance(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.setMaintenance"))
+
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.Bind(&req); err != nil {
+		logger.Warn("Failed to bind request data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if req.Enabled == nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "enabled is required")
+	}
+
+	s.SetMaintenanceMode(*req.Enabled)
+	logger.Warn("Maintenance mode changed", slog.Bool("enabled", *req.Enabled))
+
+	response := struct {
+		Enabled bool `json:"enabled" xml:"enabled"`
+	}{
+		Enabled: *req.Enabled,
+	}
+	return render(c, http.StatusOK, "maintenance", response)
+}
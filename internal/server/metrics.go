@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"L3_5/internal/metrics"
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+type serverMetrics struct {
+	registry *metrics.Registry
+	// Seats left per upcoming event; past events are dropped on refresh so
+	// the series count stays bounded
+	availableSeats *metrics.GaugeVec
+	// Bookings by what became of them, for conversion rates
+	bookingOutcomes *metrics.CounterVec
+	// Time spent in each phase of the booking transaction
+	bookingPhaseSeconds *metrics.HistogramVec
+	// Failed background worker passes by task, and when each task last
+	// succeeded or failed, so alerting can catch a stuck worker
+	workerErrors      *metrics.CounterVec
+	workerLastSuccess *metrics.GaugeVec
+	workerLastError   *metrics.GaugeVec
+}
+
+// Booking outcome label values
+const (
+	outcomeCreated         = "created"
+	outcomeConfirmed       = "confirmed"
+	outcomeCancelledByUser = "cancelled_by_user"
+	outcomeExpired         = "expired"
+)
+
+// Background worker task label values
+const (
+	taskCleanup           = "cleanup"
+	taskReservations      = "reservations"
+	taskAvailabilityGauge = "availability_gauge"
+)
+
+var workerTasks = []string{taskCleanup, taskReservations, taskAvailabilityGauge}
+
+func newServerMetrics() *serverMetrics {
+	registry := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: registry,
+		availableSeats: registry.NewGaugeVec("eventbooker_event_available_seats",
+			"Seats still available per upcoming event.", "event_id"),
+		bookingOutcomes: registry.NewCounterVec("eventbooker_booking_outcomes_total",
+			"Bookings by outcome: created, confirmed, cancelled_by_user or expired.", "outcome"),
+		bookingPhaseSeconds: registry.NewHistogramVec("eventbooker_booking_phase_seconds",
+			"Duration of the booking transaction by phase: acquire, check_availability or insert.",
+			metrics.DefaultBuckets, "phase"),
+		workerErrors: registry.NewCounterVec("eventbooker_worker_errors_total",
+			"Failed background worker passes by task: cleanup, reservations or availability_gauge.", "task"),
+		workerLastSuccess: registry.NewGaugeVec("eventbooker_worker_last_success_timestamp_seconds",
+			"Unix time of the last successful background worker pass by task.", "task"),
+		workerLastError: registry.NewGaugeVec("eventbooker_worker_last_error_timestamp_seconds",
+			"Unix time of the last failed background worker pass by task.", "task"),
+	}
+	// Start every outcome at zero so rates work before the first event
+	for _, outcome := range []string{outcomeCreated, outcomeConfirmed, outcomeCancelledByUser, outcomeExpired} {
+		m.bookingOutcomes.Add(0, outcome)
+	}
+	for _, task := range workerTasks {
+		m.workerErrors.Add(0, task)
+	}
+	return m
+}
+
+func (m *serverMetrics) observeBookingPhase(phase string, elapsed time.Duration) {
+	m.bookingPhaseSeconds.Observe(elapsed.Seconds(), phase)
+}
+
+// observeWorkerRun records the outcome of a background worker pass.
+func (m *serverMetrics) observeWorkerRun(task string, err error) {
+	now := float64(time.Now().UnixNano()) / float64(time.Second)
+	if err != nil {
+		m.workerErrors.Inc(task)
+		m.workerLastError.Set(now, task)
+		return
+	}
+	m.workerLastSuccess.Set(now, task)
+}
+
+// RefreshAvailabilityGauge sets the availability gauge from the current
+// seat counts of all upcoming events. It runs at startup so dashboards are
+// right before anything changes, and after every worker pass.
+func (s *Server) RefreshAvailabilityGauge(ctx context.Context) error {
+	events, err := s.storage.GetAllEvents(ctx, models.EventFilter{})
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+	counts, err := s.storage.GetSeatCounts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	samples := make([]metrics.Sample, 0, len(counts))
+	for id, c := range counts {
+		samples = append(samples, metrics.Sample{
+			LabelValues: []string{strconv.Itoa(id)},
+			Value:       float64(c.Available),
+		})
+	}
+	s.metrics.availableSeats.Replace(samples)
+
+	s.logger.Info("Refreshed availability gauge", slog.Int("events", len(samples)))
+	return nil
+}
+
+func (s *Server) getMetrics(c echo.Context) error {
+	c.Response().Header().Set(echo.HeaderContentType, metrics.ContentType)
+	return s.metrics.registry.WriteText(c.Response())
+}
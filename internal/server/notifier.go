@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Notifier tells booking holders about changes to events they booked.
+type Notifier interface {
+	NotifyReschedule(ctx context.Context, event models.Event, booking models.Booking) error
+	NotifyConfirmation(ctx context.Context, eventID int, userName string) error
+}
+
+// logNotifier is the default Notifier; it only records what would be sent.
+type logNotifier struct {
+	logger *slog.Logger
+}
+
+func (n logNotifier) NotifyReschedule(ctx context.Context, event models.Event, booking models.Booking) error {
+	n.logger.Info("Notifying booking of reschedule",
+		slog.Int("event_id", event.ID),
+		slog.Int("booking_id", booking.ID),
+		slog.String("user_name", booking.UserName),
+		slog.Time("date", event.Date),
+		slog.String("status", string(booking.Status)))
+	return nil
+}
+
+func (n logNotifier) NotifyConfirmation(ctx context.Context, eventID int, userName string) error {
+	n.logger.Info("Notifying booking confirmation",
+		slog.Int("event_id", eventID),
+		slog.String("user_name", userName),
+		slog.String("request_id", requestIDFrom(ctx)))
+	return nil
+}
+
+// notifyConfirmation sends the confirmation of a booking in the background,
+// so a slow webhook doesn't hold up the response. Each attempt is recorded in
+// webhook_deliveries, which also keeps a delivered notification from going
+// out twice.
+func (s *Server) notifyConfirmation(c echo.Context, bookingID, eventID int, userName string) {
+	ctx := requestContext(c)
+	logger := loggerFrom(c).With(slog.String("op", "server.notifyConfirmation"),
+		slog.Int("booking_id", bookingID), slog.Int("event_id", eventID))
+
+	s.notifications.Add(1)
+	go func() {
+		defer s.notifications.Done()
+
+		attempt, delivered, err := s.storage.BeginWebhookDelivery(ctx, bookingID, eventID)
+		if err != nil {
+			logger.Error("Failed to record confirmation notification", slog.Any("error", err))
+			return
+		}
+		if delivered {
+			logger.Info("Confirmation already notified")
+			return
+		}
+
+		if err := s.notifier.NotifyConfirmation(ctx, eventID, userName); err != nil {
+			logger.Error("Failed to notify confirmation", slog.Int("attempt", attempt), slog.Any("error", err))
+			return
+		}
+		if err := s.storage.MarkWebhookDelivered(ctx, bookingID, eventID); err != nil {
+			logger.Error("Failed to record delivered notification", slog.Any("error", err))
+		}
+	}()
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"L3_5/internal/storage"
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	organizerContextKey = "organizer_id"
+
+	maxExtensionMinutes = 24 * 60
+)
+
+// requireOrganizer authenticates organizers by their configured bearer token
+// and stores the organizer ID on the context. Valid credentials that belong
+// to no organizer, such as a booker's confirm token, are forbidden.
+func (s *Server) requireOrganizer(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		token, ok := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if !ok || token == "" {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Organizer authentication required")
+		}
+
+		for id, organizerToken := range s.cfg.Organizers.Tokens {
+			if organizerToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(organizerToken)) == 1 {
+				c.Set(organizerContextKey, id)
+				return next(c)
+			}
+		}
+
+		loggerFrom(c).Warn("Rejected organizer request", slog.String("path", c.Path()))
+		return echo.NewHTTPError(http.StatusForbidden, "Organizer permission required")
+	}
+}
+
+func organizerFrom(c echo.Context) int {
+	id, _ := c.Get(organizerContextKey).(int)
+	return id
+}
+
+func (s *Server) extendBooking(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.extendBooking"))
+
+	bookingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid booking ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid booking ID")
+	}
+
+	var request struct {
+		Minutes int `json:"minutes"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Failed to bind extension request data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if request.Minutes <= 0 || request.Minutes > maxExtensionMinutes {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "minutes must be between 1 and "+strconv.Itoa(maxExtensionMinutes))
+	}
+
+	ctx, err := ifMatchContext(c)
+	if err != nil {
+		return err
+	}
+
+	organizerID := organizerFrom(c)
+	logger.Info("Extending booking",
+		slog.Int("booking_id", bookingID),
+		slog.Int("organizer_id", organizerID),
+		slog.Int("minutes", request.Minutes))
+
+	expiresAt, err := s.storage.ExtendBookingByOrganizer(ctx, bookingID, organizerID, request.Minutes)
+	if err != nil {
+		logger.Error("Failed to extend booking", slog.Int("booking_id", bookingID), slog.Any("error", err))
+		switch {
+		case errors.Is(err, storage.ErrBookingNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found")
+		case errors.Is(err, storage.ErrNotOrganizer):
+			return echo.NewHTTPError(http.StatusForbidden, "Booking belongs to another organizer's event")
+		case errors.Is(err, storage.ErrVersionMismatch):
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "Booking was changed since it was read")
+		case errors.Is(err, storage.ErrNotPending):
+			return echo.NewHTTPError(http.StatusConflict, "Only pending bookings can be extended")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to extend booking")
+	}
+
+	response := struct {
+		ID        int       `json:"id" xml:"id"`
+		ExpiresAt time.Time `json:"expires_at" xml:"expires_at"`
+	}{
+		ID:        bookingID,
+		ExpiresAt: expiresAt,
+	}
+
+	logger.Info("Successfully extended booking", slog.Int("booking_id", bookingID), slog.Time("expires_at", expiresAt))
+	return render(c, http.StatusOK, "booking_extension", response)
+}
+
+// moveBooking moves a booking to another event of the same organizer, e.g.
+// another date of a series. The original booking is cancelled and returned
+// along with its replacement.
+func (s *Server) moveBooking(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.moveBooking"))
+
+	bookingID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid booking ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid booking ID")
+	}
+
+	var request struct {
+		EventID int `json:"event_id"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Failed to bind move request data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if request.EventID <= 0 {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "event_id must be positive")
+	}
+
+	organizerID := organizerFrom(c)
+	logger.Info("Moving booking",
+		slog.Int("booking_id", bookingID),
+		slog.Int("organizer_id", organizerID),
+		slog.Int("event_id", request.EventID))
+
+	ctx := dbContext(c)
+	original, moved, err := s.storage.MoveBooking(ctx, bookingID, organizerID, request.EventID)
+	if err != nil {
+		logger.Error("Failed to move booking", slog.Int("booking_id", bookingID), slog.Any("error", err))
+		switch {
+		case errors.Is(err, storage.ErrBookingNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found")
+		case errors.Is(err, storage.ErrEventNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+		case errors.Is(err, storage.ErrNotOrganizer):
+			return echo.NewHTTPError(http.StatusForbidden, "Booking or event belongs to another organizer")
+		case errors.Is(err, storage.ErrSameEvent):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Booking is already for this event")
+		case errors.Is(err, storage.ErrBookingCancelled):
+			return echo.NewHTTPError(http.StatusConflict, "Cancelled bookings cannot be moved")
+		case errors.Is(err, storage.ErrCheckedIn):
+			return echo.NewHTTPError(http.StatusConflict, "Checked-in bookings cannot be moved")
+		case errors.Is(err, storage.ErrBookingExpired):
+			return echo.NewHTTPError(http.StatusGone, "Booking hold has expired")
+		case errors.Is(err, storage.ErrDuplicateBooking):
+			return echo.NewHTTPError(http.StatusConflict, "User already has a booking for this event")
+		case errors.Is(err, storage.ErrInvalidSeatType):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Seat type is not offered by the target event")
+		case errors.Is(err, storage.ErrNotEnoughSeats):
+			return notEnoughSeatsHTTPError(err, s.isAdmin(c))
+		}
+		if httpErr := constraintHTTPError(err); httpErr != nil {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to move booking")
+	}
+
+	logger.Info("Successfully moved booking",
+		slog.Int("booking_id", bookingID),
+		slog.Int("new_booking_id", moved.ID),
+		slog.Int("event_id", moved.EventID))
+	s.availability.publish(original.EventID, moved.EventID)
+
+	response := struct {
+		Booking   *models.Booking `json:"booking" xml:"booking"`
+		Cancelled *models.Booking `json:"cancelled" xml:"cancelled"`
+	}{
+		Booking:   moved,
+		Cancelled: original,
+	}
+	return render(c, http.StatusCreated, "booking_move", response)
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// pagination is the page requested by a list endpoint's limit and offset
+// query parameters.
+type pagination struct {
+	Limit  int
+	Offset int
+}
+
+// parsePagination reads limit and offset, defaulting limit to the configured
+// page size and clamping it to the maximum. Endpoints paging by cursor ignore
+// Offset. The returned error is a ready 400 response.
+func (s *Server) parsePagination(c echo.Context) (pagination, error) {
+	page := pagination{Limit: s.defaultPageSize}
+
+	if raw := c.QueryParam("limit"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v <= 0 {
+			loggerFrom(c).Warn("Invalid limit parameter", slog.String("limit", raw))
+			return page, echo.NewHTTPError(http.StatusBadRequest, "invalid limit")
+		}
+		page.Limit = min(v, s.maxPageSize)
+	}
+
+	if raw := c.QueryParam("offset"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			loggerFrom(c).Warn("Invalid offset parameter", slog.String("offset", raw))
+			return page, echo.NewHTTPError(http.StatusBadRequest, "invalid offset")
+		}
+		page.Offset = v
+	}
+
+	return page, nil
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"L3_5/internal/storage"
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// bookingETag identifies a booking at its current version. It is strong,
+// since If-Match only ever matches strong tags.
+func bookingETag(booking *models.Booking) string {
+	return fmt.Sprintf(`"%d-%d"`, booking.ID, booking.Version)
+}
+
+func setBookingETag(c echo.Context, booking *models.Booking) {
+	c.Response().Header().Set("ETag", bookingETag(booking))
+}
+
+// ifMatchContext returns the database context for a change to a booking,
+// carrying the version named by the required If-Match header so that storage
+// refuses the change if the booking moved on in the meantime. "*" matches
+// any version.
+func ifMatchContext(c echo.Context) (context.Context, error) {
+	ctx := dbContext(c)
+
+	raw := strings.TrimSpace(c.Request().Header.Get("If-Match"))
+	switch raw {
+	case "":
+		return nil, echo.NewHTTPError(http.StatusPreconditionRequired, "If-Match header with the booking's ETag is required")
+	case "*":
+		return ctx, nil
+	}
+
+	var bookingID, version int
+	if _, err := fmt.Sscanf(raw, `"%d-%d"`, &bookingID, &version); err != nil || bookingETag(&models.Booking{ID: bookingID, Version: version}) != raw {
+		// Weak or foreign tags can never match
+		return nil, echo.NewHTTPError(http.StatusPreconditionFailed, "If-Match does not match the booking")
+	}
+	return storage.WithBookingVersion(ctx, bookingID, version), nil
+}
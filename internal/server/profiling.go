@@ -0,0 +1,25 @@
+package server
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/labstack/echo/v4"
+)
+
+// registerProfiling mounts the net/http/pprof handlers under /debug/pprof.
+// They sit behind admin auth, so profiling also needs an admin token.
+func (s *Server) registerProfiling() {
+	g := s.e.Group("/debug/pprof", s.requireAdmin)
+
+	index := echo.WrapHandler(http.HandlerFunc(pprof.Index))
+	g.GET("", index)
+	g.GET("/", index)
+	g.GET("/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	g.GET("/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+	g.GET("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.POST("/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	g.GET("/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+	// Named profiles such as heap and goroutine are served by the index handler
+	g.GET("/*", index)
+}
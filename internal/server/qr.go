@@ -0,0 +1,56 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"L3_5/internal/storage"
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+	"github.com/skip2/go-qrcode"
+)
+
+const qrSize = 256
+
+// signReference returns the QR payload for a booking: the reference and an
+// HMAC of it, so a scanned code can be told apart from a made-up one.
+func (s *Server) signReference(reference string) string {
+	mac := hmac.New(sha256.New, s.checkinKey)
+	mac.Write([]byte(reference))
+	return reference + "." + hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func (s *Server) getBookingQR(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getBookingQR"))
+
+	reference := c.Param("ref")
+
+	ctx := dbContext(c)
+	booking, err := s.storage.GetBookingByReference(ctx, reference)
+	if err != nil {
+		if errors.Is(err, storage.ErrBookingNotFound) {
+			logger.Warn("Booking not found", slog.String("reference", reference))
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found")
+		}
+		logger.Error("Failed to get booking", slog.String("reference", reference), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get booking")
+	}
+	if booking.Status == models.BookingCancelled {
+		logger.Warn("QR requested for cancelled booking", slog.Int("booking_id", booking.ID))
+		return echo.NewHTTPError(http.StatusConflict, "Booking is cancelled")
+	}
+
+	png, err := qrcode.Encode(s.signReference(booking.Reference), qrcode.Medium, qrSize)
+	if err != nil {
+		logger.Error("Failed to encode QR code", slog.Int("booking_id", booking.ID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to generate QR code")
+	}
+
+	logger.Info("Returned booking QR code", slog.Int("booking_id", booking.ID))
+	return c.Blob(http.StatusOK, "image/png", png)
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// rejectWritesWhenReadOnly answers every write with 503 when the service is
+// deployed read-only, e.g. in front of a replica. Unlike maintenance mode it
+// can't be switched off at runtime, so admin writes are rejected too.
+func (s *Server) rejectWritesWhenReadOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !s.readOnly {
+			return next(c)
+		}
+		switch c.Request().Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return next(c)
+		}
+		// Reads served over POST
+		if c.Path() == "/bookings/status" || c.Path() == "/admin/verify" {
+			return next(c)
+		}
+
+		loggerFrom(c).Info("Rejected write in read-only mode",
+			slog.String("method", c.Request().Method),
+			slog.String("path", c.Path()))
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "Service is read-only, writes are disabled")
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"L3_5/internal/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+var receiptTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Receipt {{.Reference}}</title>
+</head>
+<body>
+<h1>Booking receipt</h1>
+<table>
+<tr><th>Reference</th><td>{{.Reference}}</td></tr>
+<tr><th>Event</th><td>{{.EventName}}</td></tr>
+<tr><th>Date</th><td>{{.EventDate.Format "Mon, 02 Jan 2006 15:04 MST"}}</td></tr>
+<tr><th>Name</th><td>{{.UserName}}</td></tr>
+<tr><th>Seats</th><td>{{.Seats}}{{with .SeatType}} ({{.}}){{end}}</td></tr>
+{{- if .TotalPrice}}
+<tr><th>Price</th><td>{{.Seats}} &times; {{.UnitPrice}} = {{.TotalPrice}}</td></tr>
+{{- end}}
+<tr><th>Confirmed</th><td>{{.ConfirmedAt.Format "Mon, 02 Jan 2006 15:04 MST"}}</td></tr>
+</table>
+</body>
+</html>
+`))
+
+// getBookingReceipt returns the receipt of a confirmed booking as JSON or
+// XML, or as a printable HTML page when the client prefers HTML.
+func (s *Server) getBookingReceipt(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getBookingReceipt"))
+
+	reference := c.Param("ref")
+
+	ctx := dbContext(c)
+	receipt, err := s.storage.GetReceipt(ctx, reference)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrBookingNotFound):
+			logger.Warn("Booking not found", slog.String("reference", reference))
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found")
+		case errors.Is(err, storage.ErrNotConfirmed):
+			logger.Warn("Receipt requested for unconfirmed booking", slog.String("reference", reference))
+			return echo.NewHTTPError(http.StatusConflict, "Booking is not confirmed")
+		}
+		logger.Error("Failed to get receipt", slog.String("reference", reference), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get receipt")
+	}
+
+	logger.Info("Returned booking receipt", slog.String("reference", reference))
+	if !prefersHTML(c.Request().Header.Get(echo.HeaderAccept)) {
+		return render(c, http.StatusOK, "receipt", receipt)
+	}
+
+	var buf bytes.Buffer
+	if err := receiptTemplate.Execute(&buf, receipt); err != nil {
+		logger.Error("Failed to render receipt", slog.String("reference", reference), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to render receipt")
+	}
+	return c.HTMLBlob(http.StatusOK, buf.Bytes())
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// recoverPanics turns a panicking handler into a JSON 500 carrying the
+// request ID, and logs the stack with the request-scoped logger. It must run
+// after requestLogger so both know the request ID.
+func recoverPanics() echo.MiddlewareFunc {
+	return middleware.RecoverWithConfig(middleware.RecoverConfig{
+		LogErrorFunc: func(c echo.Context, err error, stack []byte) error {
+			requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+			loggerFrom(c).Error("Recovered from panic",
+				slog.String("method", c.Request().Method),
+				slog.String("path", c.Path()),
+				slog.Any("error", err),
+				slog.String("stack", string(stack)))
+			return echo.NewHTTPError(http.StatusInternalServerError, map[string]string{
+				"message":    "Internal server error",
+				"request_id": requestID,
+			}).SetInternal(err)
+		},
+	})
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"L3_5/internal/storage"
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Most instances generated for one recurring event
+const maxSeriesEvents = 100
+
+func validateRecurrence(event *models.Event, r models.Recurrence, errs *ValidationErrors) {
+	switch r.Frequency {
+	case models.FrequencyDaily, models.FrequencyWeekly:
+	default:
+		errs.add("recurrence.frequency", "frequency must be %s or %s", models.FrequencyDaily, models.FrequencyWeekly)
+	}
+	if r.Interval < 0 {
+		errs.add("recurrence.interval", "interval must not be negative")
+	}
+
+	switch {
+	case r.Count == 0 && r.Until == nil:
+		errs.add("recurrence", "either count or until is required")
+	case r.Count != 0 && r.Until != nil:
+		errs.add("recurrence", "count and until are mutually exclusive")
+	case r.Count < 0 || r.Count > maxSeriesEvents:
+		errs.add("recurrence.count", "count must be between 1 and %d", maxSeriesEvents)
+	case r.Until != nil && r.Until.Before(event.Date):
+		errs.add("recurrence.until", "until must not be before the event date")
+	case r.Until != nil && len(r.Dates(event.Date, maxSeriesEvents+1)) > maxSeriesEvents:
+		errs.add("recurrence.until", "until must not produce more than %d events", maxSeriesEvents)
+	}
+}
+
+// createRecurringEvent creates every instance of a recurring event at once,
+// linked by a series ID. The base event gives the first date and the
+// settings shared by all instances.
+func (s *Server) createRecurringEvent(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.createRecurringEvent"))
+
+	var request struct {
+		Event      models.Event      `json:"event"`
+		Recurrence models.Recurrence `json:"recurrence"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Failed to bind request data", slog.Any("error", err))
+		if errors.Is(err, models.ErrDateOutOfRange) || errors.Is(err, models.ErrInvalidTimezone) {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, errors.Unwrap(err).Error())
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	base, rule := request.Event, request.Recurrence
+
+	var errs ValidationErrors
+	errors.As(s.validateEvent(&base), &errs)
+	validateRecurrence(&base, rule, &errs)
+	if err := errs.err(); err != nil {
+		logger.Warn("Recurring event validation failed", slog.Any("error", err))
+		return validationHTTPError(err)
+	}
+
+	dates := rule.Dates(base.Date, maxSeriesEvents)
+	events := make([]models.Event, len(dates))
+	for i, date := range dates {
+		events[i] = base
+		events[i].Date = date
+		events[i].SeatTypes = slices.Clone(base.SeatTypes)
+	}
+
+	logger.Info("Creating recurring event",
+		slog.String("name", base.Name),
+		slog.String("frequency", rule.Frequency),
+		slog.Int("count", len(events)))
+
+	ctx := dbContext(c)
+	seriesID, err := s.storage.CreateEventSeries(ctx, events)
+	if err != nil {
+		logger.Error("Failed to create event series in storage", slog.Any("error", err))
+		if errors.Is(err, storage.ErrDuplicateEvent) {
+			return echo.NewHTTPError(http.StatusConflict, "Event with the same name and date already exists")
+		}
+		if httpErr := constraintHTTPError(err); httpErr != nil {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create events")
+	}
+
+	response := struct {
+		SeriesID int            `json:"series_id" xml:"series_id"`
+		Events   []models.Event `json:"events" xml:"event"`
+	}{
+		SeriesID: seriesID,
+		Events:   events,
+	}
+
+	logger.Info("Successfully created recurring event", slog.Int("series_id", seriesID), slog.Int("count", len(events)))
+	return render(c, http.StatusCreated, "series", response)
+}
+
+func (s *Server) getSeriesEvents(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getSeriesEvents"))
+
+	seriesID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid series ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid series ID")
+	}
+
+	filter, err := parseEventFilter(c)
+	if err != nil {
+		logger.Warn("Invalid filter parameters", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	filter.SeriesID = &seriesID
+
+	exists, err := s.storage.SeriesExists(dbContext(c), seriesID)
+	if err != nil {
+		logger.Error("Failed to look up series", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get events")
+	}
+	if !exists {
+		return echo.NewHTTPError(http.StatusNotFound, "Series not found")
+	}
+
+	return s.listEvents(c, logger.With(slog.Int("series_id", seriesID)), filter)
+}
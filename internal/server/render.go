@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// JSON key styles. Field tags are snake_case, so camel is a rewrite of them.
+const (
+	jsonCaseSnake = "snake"
+	jsonCaseCamel = "camel"
+
+	jsonCaseContextKey = "json_case"
+)
+
+// jsonCase records the JSON key style for the request. The case query
+// parameter overrides the configured default.
+func (s *Server) jsonCase(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		style := c.QueryParam("case")
+		switch style {
+		case "":
+			style = s.defaultJSONCase
+		case jsonCaseSnake, jsonCaseCamel:
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest, "case must be snake or camel")
+		}
+		c.Set(jsonCaseContextKey, style)
+		return next(c)
+	}
+}
+
+// render writes v as XML when the client prefers it and as JSON otherwise.
+// root names the XML document element; slices are wrapped so each element
+// becomes an <item>.
+func render(c echo.Context, code int, root string, v any) error {
+	if !prefersXML(c.Request().Header.Get(echo.HeaderAccept)) {
+		if c.Get(jsonCaseContextKey) == jsonCaseCamel {
+			return renderCamelJSON(c, code, v)
+		}
+		return c.JSON(code, v)
+	}
+
+	if v != nil && reflect.TypeOf(v).Kind() == reflect.Slice {
+		v = struct {
+			Items any `xml:"item"`
+		}{Items: v}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).EncodeElement(v, xml.StartElement{Name: xml.Name{Local: root}}); err != nil {
+		return err
+	}
+	return c.Blob(code, echo.MIMEApplicationXMLCharsetUTF8, buf.Bytes())
+}
+
+// prefersXML reports whether the Accept header ranks XML above JSON. Ties,
+// wildcards and a missing header keep the JSON default.
+func prefersXML(accept string) bool {
+	xmlQ, jsonQ, _ := acceptQualities(accept)
+	return xmlQ > 0 && xmlQ > jsonQ
+}
+
+// prefersHTML reports whether the Accept header ranks HTML above both JSON
+// and XML, as browsers do.
+func prefersHTML(accept string) bool {
+	xmlQ, jsonQ, htmlQ := acceptQualities(accept)
+	return htmlQ > 0 && htmlQ > jsonQ && htmlQ > xmlQ
+}
+
+// acceptQualities returns the highest q the Accept header gives XML, JSON
+// and HTML. Wildcards count towards JSON.
+func acceptQualities(accept string) (xmlQ, jsonQ, htmlQ float64) {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if name, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && name == "q" {
+				if v, err := strconv.ParseFloat(value, 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case echo.MIMEApplicationXML, echo.MIMETextXML:
+			xmlQ = max(xmlQ, q)
+		case echo.MIMEApplicationJSON, "*/*", "application/*":
+			jsonQ = max(jsonQ, q)
+		case echo.MIMETextHTML:
+			htmlQ = max(htmlQ, q)
+		}
+	}
+	return xmlQ, jsonQ, htmlQ
+}
+
+// renderCamelJSON writes v as JSON with every object key in camelCase, except
+// inside event metadata, whose keys are the user's own.
+func renderCamelJSON(c echo.Context, code int, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	// UseNumber keeps large integers exact through the round trip
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic any
+	if err := decoder.Decode(&generic); err != nil {
+		return err
+	}
+	out, err := json.Marshal(camelizeKeys(generic))
+	if err != nil {
+		return err
+	}
+	return c.JSONBlob(code, out)
+}
+
+func camelizeKeys(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			if key == "metadata" {
+				out[key] = value
+				continue
+			}
+			out[snakeToCamel(key)] = camelizeKeys(value)
+		}
+		return out
+	case []any:
+		for i, value := range v {
+			v[i] = camelizeKeys(value)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// snakeToCamel turns user_name into userName.
+func snakeToCamel(key string) string {
+	parts := strings.Split(key, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"L3_5/internal/storage"
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// reserveSeats holds seats of an event for the configured reservation TTL.
+// The returned token promotes the reservation to a booking.
+func (s *Server) reserveSeats(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.reserveSeats"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+
+	var reservation models.Reservation
+	if err := c.Bind(&reservation); err != nil {
+		logger.Warn("Failed to bind reservation request data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid reservation data")
+	}
+	reservation.EventID = eventID
+
+	if err := validateBooking(&models.Booking{UserName: reservation.UserName, Seats: reservation.Seats}); err != nil {
+		logger.Warn("Reservation validation failed", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	logger.Info("Reservation request",
+		slog.String("user_name", reservation.UserName),
+		slog.Int("seats", reservation.Seats),
+		slog.Int("event_id", eventID))
+
+	ctx := dbContext(c)
+	if err := s.storage.CreateReservation(ctx, &reservation, s.reservationTTL); err != nil {
+		logger.Error("Failed to reserve seats", slog.String("user_name", reservation.UserName), slog.Any("error", err))
+		switch {
+		case errors.Is(err, storage.ErrEventNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+		case errors.Is(err, storage.ErrNotEnoughSeats):
+			return notEnoughSeatsHTTPError(err, s.isAdmin(c))
+		case errors.Is(err, storage.ErrInvalidSeatType):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Events with seat types can't be reserved")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reserve seats")
+	}
+
+	logger.Info("Successfully reserved seats",
+		slog.Int("reservation_id", reservation.ID),
+		slog.Time("expires_at", reservation.ExpiresAt))
+	s.availability.publish(eventID)
+	return render(c, http.StatusCreated, "reservation", reservation)
+}
+
+// bookReservation promotes a reservation to a pending booking of the same
+// seats, which is then confirmed as any other booking.
+func (s *Server) bookReservation(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.bookReservation"))
+
+	reservationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid reservation ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid reservation ID")
+	}
+
+	var request struct {
+		Token string `json:"token"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Failed to bind reservation booking data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if request.Token == "" {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "token is required")
+	}
+
+	logger.Info("Booking reservation", slog.Int("reservation_id", reservationID))
+
+	ctx := storage.WithPhaseObserver(dbContext(c), s.metrics.observeBookingPhase)
+	booking, left, err := s.storage.PromoteReservation(ctx, reservationID, request.Token)
+	if err != nil {
+		logger.Error("Failed to book reservation", slog.Int("reservation_id", reservationID), slog.Any("error", err))
+		switch {
+		case errors.Is(err, storage.ErrReservationNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Reservation not found or expired")
+		case errors.Is(err, storage.ErrNotEnoughSeats):
+			return notEnoughSeatsHTTPError(err, s.isAdmin(c))
+		case errors.Is(err, storage.ErrDuplicateBooking):
+			return echo.NewHTTPError(http.StatusConflict, "User already has a booking for this event")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to book reservation")
+	}
+
+	logger.Info("Successfully booked reservation",
+		slog.Int("reservation_id", reservationID),
+		slog.Int("booking_id", booking.ID),
+		slog.Int("event_id", booking.EventID))
+	s.availability.publish(booking.EventID)
+	s.metrics.bookingOutcomes.Inc(outcomeCreated)
+
+	s.setAvailabilityHeaders(c, left)
+	setBookingETag(c, booking)
+	return render(c, http.StatusCreated, "booking", booking)
+}
+
+// expireReservations releases reservations past their TTL. It runs on its
+// own ticker, more often than the bookings cleanup.
+func (s *Server) expireReservations(ctx context.Context) {
+	if s.readOnly {
+		return
+	}
+	eventIDs, expired, err := s.storage.ExpireReservations(ctx)
+	s.metrics.observeWorkerRun(taskReservations, err)
+	if err != nil {
+		s.logger.Error("Failed to release expired reservations", slog.Any("error", err))
+		return
+	}
+	if expired > 0 {
+		s.logger.Info("Released expired reservations", slog.Any("event_ids", eventIDs), slog.Int64("expired", expired))
+	}
+	s.availability.publish(eventIDs...)
+}
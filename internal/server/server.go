@@ -2,9 +2,20 @@ package server
 
 import (
 	"context"
-	"log"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"L3_5/internal/storage"
@@ -14,238 +25,1752 @@ import (
 	"github.com/labstack/echo/v4/middleware"
 )
 
+const (
+	// Most ids accepted by one batch status lookup
+	maxBatchIDs = 100
+
+	// Consecutive failed cleanups before /readyz reports degraded
+	cleanupFailureThreshold = 3
+	// Seat counts are stored in INTEGER columns
+	maxSeatCount = math.MaxInt32
+)
+
 type Server struct {
-	storage *storage.Storage
-	e       *echo.Echo
+	storage  *storage.Storage
+	cfg      *models.Config
+	logger   *slog.Logger
+	e        *echo.Echo
+	notifier Notifier
+	metrics  *serverMetrics
+	// Notifications still being sent after their request returned
+	notifications sync.WaitGroup
+	// Wakes long-poll requests when availability changes
+	availability *availabilityBus
+
+	minPaymentTime  int
+	maxTotalSeats   int
+	defaultPageSize int
+	maxPageSize     int
+	defaultJSONCase string
+	checkinKey      []byte
+
+	workerInterval  time.Duration
+	feedInterval    time.Duration
+	longPollTimeout time.Duration
+	startedAt       time.Time
+	maintenance     atomic.Bool
+	// Set by server.read_only; writes are rejected and the worker stays idle
+	readOnly bool
+	// Reservations hold seats for reservationTTL; the worker releases
+	// expired ones every reservationInterval
+	reservationTTL      time.Duration
+	reservationInterval time.Duration
+	// Unix nanoseconds of the last successful cleanup, zero until the first one
+	lastCleanup atomic.Int64
+	// Failed cleanups since the last success, and the latest error
+	cleanupFailures  atomic.Int64
+	lastCleanupError atomic.Pointer[workerError]
+}
+
+// workerError is a failure of a background worker pass.
+type workerError struct {
+	Message string
+	At      time.Time
 }
 
-func New(storage *storage.Storage) *Server {
+// New builds the server with its routes. It fails only when no checkin
+// signing key is configured and a random one can't be generated.
+func New(storage *storage.Storage, cfg *models.Config, logger *slog.Logger) (*Server, error) {
+	// Work on a copy so the caller's config isn't changed, while /admin/config
+	// still shows the settings in effect
+	effective := *cfg
+	effective.ApplyDefaults()
+	cfg = &effective
+
 	s := &Server{
 		storage: storage,
+		cfg:     cfg,
+		logger:  logger,
 		e:       echo.New(),
+
+		notifier:     newNotifier(cfg, logger),
+		metrics:      newServerMetrics(),
+		availability: newAvailabilityBus(),
+
+		minPaymentTime:  cfg.Events.MinPaymentTime,
+		maxTotalSeats:   cfg.Events.MaxTotalSeats,
+		defaultPageSize: cfg.API.DefaultPageSize,
+		maxPageSize:     cfg.API.MaxPageSize,
+
+		workerInterval:  cfg.Worker.Interval,
+		feedInterval:    defaultFeedInterval,
+		longPollTimeout: cfg.API.LongPollTimeout,
+		startedAt:       time.Now(),
+		readOnly:        cfg.Server.ReadOnly,
+
+		reservationTTL:      cfg.Events.ReservationTTL,
+		reservationInterval: cfg.Worker.ReservationInterval,
+	}
+	if s.maxTotalSeats > maxSeatCount {
+		logger.Warn("events.max_total_seats exceeds the storable maximum, clamping",
+			slog.Int("max_total_seats", s.maxTotalSeats), slog.Int("max", maxSeatCount))
+		s.maxTotalSeats = maxSeatCount
+	}
+	if s.defaultPageSize > s.maxPageSize {
+		logger.Warn("api.default_page_size exceeds api.max_page_size, clamping",
+			slog.Int("default_page_size", s.defaultPageSize), slog.Int("max_page_size", s.maxPageSize))
+		s.defaultPageSize = s.maxPageSize
+	}
+	switch cfg.Server.JSONCase {
+	case jsonCaseSnake, jsonCaseCamel:
+		s.defaultJSONCase = cfg.Server.JSONCase
+	default:
+		logger.Warn("Unknown server.json_case, using snake", slog.String("json_case", cfg.Server.JSONCase))
+		s.defaultJSONCase = jsonCaseSnake
+	}
+	s.checkinKey = []byte(cfg.Checkin.SigningKey)
+	if len(s.checkinKey) == 0 {
+		// QR codes then stop verifying after a restart, which is fine for development only
+		logger.Warn("No checkin.signing_key configured, using a random key")
+		s.checkinKey = make([]byte, 32)
+		if _, err := rand.Read(s.checkinKey); err != nil {
+			return nil, fmt.Errorf("server.New: generate checkin signing key: %v", err)
+		}
 	}
 
 	// Add middleware for logging
 	s.e.Use(middleware.Logger())
-	s.e.Use(middleware.Recover())
 	s.e.Use(middleware.RequestID())
+	s.e.Use(s.requestLogger)
+	s.e.Use(recoverPanics())
+	s.e.Use(stampServerTime)
+	s.e.Use(s.dbTimeout)
+	s.e.Use(s.jsonCase)
+	s.e.Use(s.rejectWritesWhenReadOnly)
+	s.e.Use(s.rejectWritesInMaintenance)
+
+	s.maintenance.Store(cfg.Server.MaintenanceMode)
 
 	s.setupRoutes()
-	return s
+	return s, nil
 }
 
 func (s *Server) setupRoutes() {
 	s.e.POST("/events", s.createEvent)
+	s.e.POST("/events/batch", s.createEventBatch)
+	s.e.POST("/events/recurring", s.createRecurringEvent)
 	s.e.GET("/events", s.getEvents)
+	s.e.GET("/events/availability/longpoll", s.longPollAvailability)
+	s.e.GET("/events/next", s.getNextEvent)
 	s.e.POST("/events/:id/book", s.bookEvent)
+	s.e.POST("/events/:id/book-group", s.bookGroup)
 	s.e.POST("/events/:id/confirm", s.confirmBooking)
+	s.e.POST("/events/:id/confirm-partial", s.confirmPartial)
 	s.e.GET("/events/:id", s.getEvent)
+	s.e.GET("/events/:id/bookings", s.getEventBookings)
+	s.e.GET("/events/:id/bookings/by-user", s.getBookingForUser)
+	s.e.GET("/events/:id/availability/stream", s.streamAvailability)
+	s.e.GET("/events/:id/utilization", s.getUtilization)
+	s.e.POST("/events/:id/waitlist", s.joinWaitlist)
+	s.e.GET("/events/:id/waitlist/position", s.getWaitlistPosition)
+	s.e.PATCH("/events/:id", s.patchEvent)
+	s.e.POST("/events/:id/reschedule", s.rescheduleEvent, s.requireOrganizer)
+	s.e.HEAD("/events/:id", s.headEvent)
+	s.e.GET("/users/:name/bookings", s.getUserBookings)
+	s.e.GET("/users/:name/events/unbooked", s.getUnbookedEvents)
+	s.e.GET("/organizers/:id/events", s.getOrganizerEvents)
+	s.e.GET("/series/:id/events", s.getSeriesEvents)
+	s.e.POST("/events/:id/reserve", s.reserveSeats)
+	s.e.POST("/reservations/:id/book", s.bookReservation)
+	s.e.POST("/bookings/status", s.getBookingStatuses)
+	s.e.POST("/bookings/:id/extend", s.extendBooking, s.requireOrganizer)
+	s.e.POST("/bookings/:id/move", s.moveBooking, s.requireOrganizer)
+	s.e.GET("/bookings/:ref/qr", s.getBookingQR)
+	s.e.GET("/bookings/:ref/receipt", s.getBookingReceipt)
+	s.e.POST("/bookings/:ref/checkin", s.checkIn, s.requireOrganizer)
+	s.e.POST("/bookings/:ref/cancel", s.cancelBooking)
+	s.e.POST("/bookings/:ref/heartbeat", s.heartbeatBooking)
+	s.e.POST("/bookings/:ref/refund", s.refundBooking, s.requireOrganizer)
+	s.e.GET("/healthz", s.healthz)
+	s.e.GET("/readyz", s.readyz)
+	s.e.GET("/metrics", s.getMetrics)
+
+	admin := s.e.Group("/admin", s.requireAdmin)
+	admin.GET("/config", s.getConfig)
+	admin.GET("/expired", s.getExpiredPending)
+	admin.GET("/worker", s.getWorker)
+	admin.GET("/events", s.getAdminEvents)
+	admin.DELETE("/events", s.deleteEvents)
+	admin.POST("/events/recompute", s.recomputeAllSeats)
+	admin.POST("/events/:id/recompute", s.recomputeSeats)
+	admin.POST("/verify", s.verifyIntegrity)
+	admin.PUT("/maintenance", s.setMaintenance)
+	admin.GET("/export/events.ndjson", s.exportEvents)
+	admin.POST("/import/events", s.importEvents)
+
+	if s.cfg.Server.EnableProfiling {
+		s.registerProfiling()
+	}
 	s.e.Static("/", "web")
 }
 
+// SetNotifier replaces the default Notifier, which only logs.
+func (s *Server) SetNotifier(n Notifier) {
+	s.notifier = n
+}
+
 func (s *Server) Start(port string) error {
 	return s.e.Start(":" + port)
 }
 
+// Shutdown stops accepting connections and waits for in-flight requests, and
+// the notifications they started, to finish, or for ctx to end. Start then
+// returns http.ErrServerClosed.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.e.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.notifications.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (s *Server) createEvent(c echo.Context) error {
-	const op = "server.createEvent"
-	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	logger := loggerFrom(c).With(slog.String("op", "server.createEvent"))
 
-	log.Printf("[%s] %s: Starting event creation request from IP: %s", requestID, op, c.RealIP())
+	logger.Info("Starting event creation request")
 
 	var event models.Event
 	if err := c.Bind(&event); err != nil {
-		log.Printf("[%s] %s: Failed to bind request data: %v", requestID, op, err)
+		logger.Warn("Failed to bind request data", slog.Any("error", err))
+		if errors.Is(err, models.ErrDateOutOfRange) || errors.Is(err, models.ErrInvalidTimezone) {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, errors.Unwrap(err).Error())
+		}
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
 	}
 
-	log.Printf("[%s] %s: Creating event - Name: %s, Date: %s, Total Seats: %d, Payment Time: %d min",
-		requestID, op, event.Name, event.Date.Format("2006-01-02 15:04:05"), event.TotalSeats, event.PaymentTime)
+	logger.Info("Creating event",
+		slog.String("name", event.Name),
+		slog.Time("date", event.Date),
+		slog.Int("total_seats", event.TotalSeats),
+		slog.Int("payment_time", event.PaymentTime))
+
+	if err := s.validateEvent(&event); err != nil {
+		logger.Warn("Event validation failed", slog.Any("error", err))
+		return validationHTTPError(err)
+	}
 
-	ctx := context.Background()
+	ctx := dbContext(c)
 	if err := s.storage.CreateEvent(ctx, &event); err != nil {
-		log.Printf("[%s] %s: Failed to create event in storage: %v", requestID, op, err)
+		if errors.Is(err, storage.ErrEventExists) {
+			logger.Info("Returning existing identical event", slog.Int("event_id", event.ID))
+			return render(c, http.StatusOK, "event", event)
+		}
+		logger.Error("Failed to create event in storage", slog.Any("error", err))
+		if errors.Is(err, storage.ErrDuplicateEvent) {
+			return echo.NewHTTPError(http.StatusConflict, "Event with the same name and date already exists")
+		}
+		if httpErr := constraintHTTPError(err); httpErr != nil {
+			return httpErr
+		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create event")
 	}
 
-	log.Printf("[%s] %s: Successfully created event with ID: %d", requestID, op, event.ID)
-	return c.JSON(http.StatusCreated, event)
+	logger.Info("Successfully created event", slog.Int("event_id", event.ID))
+	return render(c, http.StatusCreated, "event", event)
+}
+
+func (s *Server) validateEvent(event *models.Event) error {
+	return s.eventErrors(event, false).err()
+}
+
+// eventErrors lists what is wrong with event. Restored events may lie in the
+// past, as a backup holds events that already took place.
+func (s *Server) eventErrors(event *models.Event, restore bool) ValidationErrors {
+	var errs ValidationErrors
+	if strings.TrimSpace(event.Name) == "" {
+		errs.add("name", "name must not be empty")
+	}
+	if event.TotalSeats <= 0 {
+		errs.add("total_seats", "total_seats must be positive")
+	} else if event.TotalSeats > s.maxTotalSeats {
+		errs.add("total_seats", "total_seats must be at most %d", s.maxTotalSeats)
+	}
+	if !restore && !event.Date.After(time.Now()) {
+		errs.add("date", "date must be in the future")
+	}
+	if event.PaymentTime < s.minPaymentTime {
+		errs.add("payment_time", "payment_time must be at least %d minutes", s.minPaymentTime)
+	}
+	if event.GraceMinutes < 0 {
+		errs.add("grace_minutes", "grace_minutes must not be negative")
+	}
+	if event.OrganizerID != nil && *event.OrganizerID <= 0 {
+		errs.add("organizer_id", "organizer_id must be positive")
+	}
+	if event.HideExactBelow < 0 {
+		errs.add("hide_exact_below", "hide_exact_below must not be negative")
+	}
+	if event.CancellationDeadlineHours != nil && *event.CancellationDeadlineHours < 0 {
+		errs.add("cancellation_deadline_hours", "cancellation_deadline_hours must not be negative")
+	}
+	if event.Metadata != nil {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(event.Metadata, &fields); err != nil {
+			errs.add("metadata", "metadata must be a JSON object")
+		}
+	}
+	validateSeatTypes(event, &errs)
+	return errs
+}
+
+func validateSeatTypes(event *models.Event, errs *ValidationErrors) {
+	if len(event.SeatTypes) == 0 {
+		return
+	}
+	seen := make(map[string]bool, len(event.SeatTypes))
+	var sum int64
+	for _, st := range event.SeatTypes {
+		if st.Type == "" {
+			errs.add("seat_types", "seat type must have a name")
+		} else if seen[st.Type] {
+			errs.add("seat_types", "seat type %q is listed twice", st.Type)
+		}
+		seen[st.Type] = true
+		if st.Total <= 0 {
+			errs.add("seat_types", "seat type %q must have a positive total", st.Type)
+		} else if st.Total > maxSeatCount {
+			errs.add("seat_types", "seat type %q must have a total of at most %d", st.Type, maxSeatCount)
+		}
+		if st.Price < 0 {
+			errs.add("seat_types", "seat type %q must not have a negative price", st.Type)
+		} else if st.Price > math.MaxInt32 {
+			errs.add("seat_types", "seat type %q must have a price of at most %d", st.Type, math.MaxInt32)
+		}
+		sum += int64(st.Total)
+	}
+	// Only meaningful once total_seats itself is valid
+	if sum != int64(event.TotalSeats) && event.TotalSeats > 0 {
+		errs.add("seat_types", "seat type totals add up to %d, total_seats is %d", sum, event.TotalSeats)
+	}
+}
+
+func (s *Server) validateEventPatch(patch *models.EventPatch) error {
+	if patch.Empty() {
+		return fmt.Errorf("no fields to update")
+	}
+	if patch.Name != nil && strings.TrimSpace(*patch.Name) == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if patch.TotalSeats != nil && *patch.TotalSeats <= 0 {
+		return fmt.Errorf("total_seats must be positive")
+	}
+	if patch.TotalSeats != nil && *patch.TotalSeats > s.maxTotalSeats {
+		return fmt.Errorf("total_seats must be at most %d", s.maxTotalSeats)
+	}
+	if patch.Date != nil && !patch.Date.After(time.Now()) {
+		return fmt.Errorf("date must be in the future")
+	}
+	if patch.PaymentTime != nil && *patch.PaymentTime < s.minPaymentTime {
+		return fmt.Errorf("payment_time must be at least %d minutes", s.minPaymentTime)
+	}
+	if patch.GraceMinutes != nil && *patch.GraceMinutes < 0 {
+		return fmt.Errorf("grace_minutes must not be negative")
+	}
+	if patch.HideExactBelow != nil && *patch.HideExactBelow < 0 {
+		return fmt.Errorf("hide_exact_below must not be negative")
+	}
+	if patch.CancellationDeadlineHours != nil && *patch.CancellationDeadlineHours < 0 {
+		return fmt.Errorf("cancellation_deadline_hours must not be negative")
+	}
+	return nil
+}
+
+func validateBooking(booking *models.Booking) error {
+	if booking.UserName == "" {
+		return fmt.Errorf("user_name is required")
+	}
+	if booking.Seats <= 0 {
+		return fmt.Errorf("seats must be positive")
+	}
+	if booking.Seats > maxSeatCount {
+		return fmt.Errorf("seats must be at most %d", maxSeatCount)
+	}
+	if booking.MinSeats < 0 || booking.MinSeats > booking.Seats {
+		return fmt.Errorf("min_seats must be between 1 and seats")
+	}
+	return nil
 }
 
 func (s *Server) getEvents(c echo.Context) error {
-	const op = "server.getEvents"
-	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	logger := loggerFrom(c).With(slog.String("op", "server.getEvents"))
+
+	filter, err := parseEventFilter(c)
+	if err != nil {
+		logger.Warn("Invalid filter parameters", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return s.listEvents(c, logger, filter)
+}
+
+// getNextEvent returns the soonest upcoming event that can still be booked.
+func (s *Server) getNextEvent(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getNextEvent"))
+
+	logger.Info("Getting next upcoming event")
+
+	ctx := dbContext(c)
+	event, err := s.storage.GetNextEvent(ctx)
+	if errors.Is(err, storage.ErrEventNotFound) {
+		logger.Info("No upcoming event with available seats")
+		return echo.NewHTTPError(http.StatusNotFound, "No upcoming event with available seats")
+	}
+	if err != nil {
+		logger.Error("Failed to get next event", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get next event")
+	}
+
+	eventsWithSeats, err := s.withAvailableSeats(ctx, []models.Event{*event}, s.isAdmin(c))
+	if err != nil {
+		logger.Error("Failed to get available seats", slog.Int("event_id", event.ID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get available seats")
+	}
+
+	logger.Info("Retrieved next event", slog.Int("event_id", event.ID))
+	return render(c, http.StatusOK, "event", eventsWithSeats[0])
+}
+
+func (s *Server) getOrganizerEvents(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getOrganizerEvents"))
+
+	organizerID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid organizer ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid organizer ID")
+	}
+
+	filter, err := parseEventFilter(c)
+	if err != nil {
+		logger.Warn("Invalid filter parameters", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	filter.OrganizerID = &organizerID
+
+	return s.listEvents(c, logger.With(slog.Int("organizer_id", organizerID)), filter)
+}
+
+func parseEventFilter(c echo.Context) (models.EventFilter, error) {
+	var filter models.EventFilter
+
+	// Past events are hidden unless explicitly requested
+	if raw := c.QueryParam("include_past"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return filter, fmt.Errorf("invalid include_past")
+		}
+		filter.IncludePast = v
+	}
+	filter.Query = strings.TrimSpace(c.QueryParam("q"))
+
+	// meta.<key>=<value> requires that metadata value
+	for name, values := range c.QueryParams() {
+		key, ok := strings.CutPrefix(name, "meta.")
+		if !ok {
+			continue
+		}
+		if key == "" {
+			return filter, fmt.Errorf("metadata filter needs a key, as in meta.sponsor=acme")
+		}
+		if filter.Metadata == nil {
+			filter.Metadata = make(map[string]string)
+		}
+		filter.Metadata[key] = values[0]
+	}
+
+	return filter, nil
+}
+
+// constraintHTTPError turns a database constraint violation into a 422
+// naming the broken rule, or returns nil for any other error.
+func constraintHTTPError(err error) *echo.HTTPError {
+	var constraintErr *storage.ConstraintError
+	if errors.As(err, &constraintErr) {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, constraintErr.Rule)
+	}
+	return nil
+}
+
+// notEnoughSeatsHTTPError is the 409 for a booking that doesn't fit, stating
+// the shortfall when storage reports one. Below the event's hide_exact_below
+// threshold only the request is repeated, unless exact is set, as the
+// shortfall would give the count away.
+func notEnoughSeatsHTTPError(err error, exact bool) *echo.HTTPError {
+	var shortfall *storage.ShortfallError
+	if !errors.As(err, &shortfall) {
+		return echo.NewHTTPError(http.StatusConflict, "Not enough available seats")
+	}
+	if !exact && shortfall.Low() {
+		return echo.NewHTTPError(http.StatusConflict, struct {
+			Message         string `json:"message"`
+			Requested       int64  `json:"requested"`
+			LowAvailability bool   `json:"low_availability"`
+		}{
+			Message:         fmt.Sprintf("Not enough available seats: requested %d", shortfall.Requested),
+			Requested:       shortfall.Requested,
+			LowAvailability: true,
+		})
+	}
+	return echo.NewHTTPError(http.StatusConflict, struct {
+		Message   string `json:"message"`
+		Requested int64  `json:"requested"`
+		Available int64  `json:"available"`
+		Shortfall int64  `json:"shortfall"`
+	}{
+		Message: fmt.Sprintf("Not enough available seats: requested %d, available %d, short by %d",
+			shortfall.Requested, max(shortfall.Available, 0), shortfall.Shortfall()),
+		Requested: shortfall.Requested,
+		Available: max(shortfall.Available, 0),
+		Shortfall: shortfall.Shortfall(),
+	})
+}
 
-	log.Printf("[%s] %s: Getting all events request from IP: %s", requestID, op, c.RealIP())
+// parseTimeParam accepts a date (2006-01-02, midnight UTC) or an RFC 3339
+// timestamp and reports which of the two it was.
+func parseTimeParam(raw string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.Parse(time.DateOnly, raw); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, raw)
+	return t, false, err
+}
+
+func (s *Server) listEvents(c echo.Context, logger *slog.Logger, filter models.EventFilter) error {
+	// A cursor, even an empty one for the first page, asks for cursor paging;
+	// a limit or offset for numbered pages with a total
+	_, hasCursor := c.QueryParams()["cursor"]
+	switch {
+	case hasCursor:
+		if c.QueryParam("offset") != "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "offset can't be combined with cursor")
+		}
+		// Cursors follow date order, which ranked search results don't
+		if filter.Query != "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "q can't be combined with cursor paging, page it with offset")
+		}
+		return s.listEventsPage(c, logger, filter)
+	case c.QueryParam("offset") != "" || c.QueryParam("limit") != "":
+		return s.listEventsOffsetPage(c, logger, filter)
+	}
 
-	ctx := context.Background()
+	logger.Info("Getting all events request", slog.Bool("include_past", filter.IncludePast), slog.String("q", filter.Query))
+
+	ctx := dbContext(c)
 
 	// Get list of events
-	events, err := s.storage.GetAllEvents(ctx)
+	events, err := s.storage.GetAllEvents(ctx, filter)
 	if err != nil {
-		log.Printf("[%s] %s: Failed to get events from storage: %v", requestID, op, err)
+		logger.Error("Failed to get events from storage", slog.Any("error", err))
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get events")
 	}
 
-	log.Printf("[%s] %s: Retrieved %d events from storage", requestID, op, len(events))
+	logger.Info("Retrieved events from storage", slog.Int("count", len(events)))
+
+	eventsWithSeats, err := s.withAvailableSeats(ctx, events, s.isAdmin(c))
+	if err != nil {
+		logger.Error("Failed to get available seats", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get available seats")
+	}
+
+	logger.Info("Successfully returned events with seat availability", slog.Int("count", len(eventsWithSeats)))
+	return render(c, http.StatusOK, "events", eventsWithSeats)
+}
 
-	// For each event, get available seats count
-	type EventWithAvailableSeats struct {
-		models.Event
-		AvailableSeats int `json:"available_seats"`
+func (s *Server) listEventsPage(c echo.Context, logger *slog.Logger, filter models.EventFilter) error {
+	page, err := s.parsePagination(c)
+	if err != nil {
+		return err
 	}
+	limit := page.Limit
 
-	var eventsWithSeats []EventWithAvailableSeats
-	for _, event := range events {
-		available, err := s.storage.GetAvailableSeats(ctx, event.ID)
+	var after *models.EventCursor
+	if raw := c.QueryParam("cursor"); raw != "" {
+		cursor, err := decodeEventCursor(raw)
 		if err != nil {
-			log.Printf("[%s] %s: Failed to get available seats for event ID %d: %v", requestID, op, event.ID, err)
-			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get available seats")
+			logger.Warn("Invalid cursor parameter", slog.String("cursor", raw), slog.Any("error", err))
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
 		}
-		eventsWithSeats = append(eventsWithSeats, EventWithAvailableSeats{
-			Event:          event,
-			AvailableSeats: available,
-		})
+		after = &cursor
+	}
+
+	logger.Info("Getting events page", slog.Int("limit", limit), slog.Bool("has_cursor", after != nil))
+
+	ctx := dbContext(c)
+	events, next, err := s.storage.GetEventsAfterCursor(ctx, filter, after, limit)
+	if err != nil {
+		logger.Error("Failed to get events page from storage", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get events")
+	}
+
+	eventsWithSeats, err := s.withAvailableSeats(ctx, events, s.isAdmin(c))
+	if err != nil {
+		logger.Error("Failed to get available seats", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get available seats")
+	}
+
+	response := struct {
+		Events []EventWithAvailableSeats `json:"events" xml:"event"`
+		Next   string                    `json:"next,omitempty" xml:"next,omitempty"`
+		AsOf   time.Time                 `json:"as_of" xml:"as_of"`
+	}{
+		Events: eventsWithSeats,
+		AsOf:   serverTimeFrom(c),
+	}
+	if next != nil {
+		response.Next = encodeEventCursor(*next)
+	}
+
+	logger.Info("Successfully returned events page", slog.Int("count", len(eventsWithSeats)), slog.Bool("has_next", next != nil))
+	return render(c, http.StatusOK, "events", response)
+}
+
+func (s *Server) listEventsOffsetPage(c echo.Context, logger *slog.Logger, filter models.EventFilter) error {
+	page, err := s.parsePagination(c)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Getting events page by offset", slog.Int("limit", page.Limit), slog.Int("offset", page.Offset))
+
+	ctx := dbContext(c)
+	events, total, err := s.storage.GetAllEventsPaginated(ctx, filter, page.Limit, page.Offset)
+	if err != nil {
+		logger.Error("Failed to get events page from storage", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get events")
 	}
 
-	log.Printf("[%s] %s: Successfully returned %d events with seat availability", requestID, op, len(eventsWithSeats))
-	return c.JSON(http.StatusOK, eventsWithSeats)
+	eventsWithSeats, err := s.withAvailableSeats(ctx, events, s.isAdmin(c))
+	if err != nil {
+		logger.Error("Failed to get available seats", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get available seats")
+	}
+
+	response := struct {
+		Events []EventWithAvailableSeats `json:"events" xml:"event"`
+		Total  int                       `json:"total" xml:"total"`
+		Limit  int                       `json:"limit" xml:"limit"`
+		Offset int                       `json:"offset" xml:"offset"`
+		AsOf   time.Time                 `json:"as_of" xml:"as_of"`
+	}{
+		Events: eventsWithSeats,
+		Total:  total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+		AsOf:   serverTimeFrom(c),
+	}
+
+	logger.Info("Successfully returned events page", slog.Int("count", len(eventsWithSeats)), slog.Int("total", total))
+	return render(c, http.StatusOK, "events", response)
+}
+
+// EventWithAvailableSeats is an event with its seat counts. The counts are
+// nil, and LowAvailability set, when the event hides exact availability.
+type EventWithAvailableSeats struct {
+	models.Event
+	*models.SeatCounts
+	LowAvailability bool `json:"low_availability,omitempty" xml:"low_availability,omitempty"`
+}
+
+// UnmarshalJSON decodes the event with Event.UnmarshalJSON, which would
+// otherwise be promoted and drop the seat counts.
+func (e *EventWithAvailableSeats) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &e.Event); err != nil {
+		return err
+	}
+	var aux struct {
+		*models.SeatCounts
+		LowAvailability bool `json:"low_availability"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	e.SeatCounts = aux.SeatCounts
+	e.LowAvailability = aux.LowAvailability
+	return nil
+}
+
+// withAvailableSeats attaches seat counts to events, hiding them below each
+// event's hide_exact_below threshold unless exact is set.
+func (s *Server) withAvailableSeats(ctx context.Context, events []models.Event, exact bool) ([]EventWithAvailableSeats, error) {
+	ids := make([]int, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.ID)
+	}
+
+	counts, err := s.storage.GetSeatCounts(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	eventsWithSeats := make([]EventWithAvailableSeats, 0, len(events))
+	for _, event := range events {
+		seatCounts := counts[event.ID]
+		item := EventWithAvailableSeats{Event: event, SeatCounts: &seatCounts}
+		if !exact && event.LowAvailability(seatCounts.Available) {
+			item.SeatCounts = nil
+			item.LowAvailability = true
+		}
+		eventsWithSeats = append(eventsWithSeats, item)
+	}
+	return eventsWithSeats, nil
 }
 
 func (s *Server) bookEvent(c echo.Context) error {
-	const op = "server.bookEvent"
-	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	logger := loggerFrom(c).With(slog.String("op", "server.bookEvent"))
 
 	eventID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		log.Printf("[%s] %s: Invalid event ID parameter: %s from IP: %s", requestID, op, c.Param("id"), c.RealIP())
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
 	}
 
-	log.Printf("[%s] %s: Starting seat booking for event ID: %d from IP: %s", requestID, op, eventID, c.RealIP())
+	logger.Info("Starting seat booking", slog.Int("event_id", eventID))
 
 	var booking models.Booking
 	if err := c.Bind(&booking); err != nil {
-		log.Printf("[%s] %s: Failed to bind booking request data: %v", requestID, op, err)
+		logger.Warn("Failed to bind booking request data", slog.Any("error", err))
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid booking data")
 	}
 	booking.EventID = eventID
 
-	log.Printf("[%s] %s: Booking request - User: %s, Seats: %d, Event ID: %d",
-		requestID, op, booking.UserName, booking.Seats, booking.EventID)
+	if err := validateBooking(&booking); err != nil {
+		logger.Warn("Booking validation failed", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
 
-	ctx := context.Background()
-	if err := s.storage.BookSeats(ctx, &booking); err != nil {
-		log.Printf("[%s] %s: Failed to book seats for user %s: %v", requestID, op, booking.UserName, err)
-		if err.Error() == "storage.BookSeats: not enough seats" {
-			return echo.NewHTTPError(http.StatusConflict, "Not enough available seats")
+	logger.Info("Booking request",
+		slog.String("user_name", booking.UserName),
+		slog.Int("seats", booking.Seats),
+		slog.Int("min_seats", booking.MinSeats),
+		slog.Int("event_id", booking.EventID))
+
+	ctx := storage.WithPhaseObserver(dbContext(c), s.metrics.observeBookingPhase)
+	left, err := s.storage.BookSeatsWithAvailability(ctx, &booking)
+	if err != nil {
+		logger.Error("Failed to book seats", slog.String("user_name", booking.UserName), slog.Any("error", err))
+		if errors.Is(err, storage.ErrEventNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+		}
+		if errors.Is(err, storage.ErrNotEnoughSeats) {
+			return notEnoughSeatsHTTPError(err, s.isAdmin(c))
+		}
+		if errors.Is(err, storage.ErrInvalidSeatType) {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Unknown or missing seat_type for this event")
+		}
+		if errors.Is(err, storage.ErrDuplicateBooking) {
+			return echo.NewHTTPError(http.StatusConflict, "User already has a booking for this event")
 		}
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to book seats")
 	}
 
-	log.Printf("[%s] %s: Successfully created booking ID: %d for user: %s, seats: %d, event: %d",
-		requestID, op, booking.ID, booking.UserName, booking.Seats, booking.EventID)
-	return c.JSON(http.StatusCreated, booking)
+	logger.Info("Successfully created booking",
+		slog.Int("booking_id", booking.ID),
+		slog.String("user_name", booking.UserName),
+		slog.Int("seats", booking.Seats),
+		slog.Int("event_id", booking.EventID))
+	s.availability.publish(eventID)
+	s.metrics.bookingOutcomes.Inc(outcomeCreated)
+
+	s.setAvailabilityHeaders(c, left)
+	setBookingETag(c, &booking)
+	return render(c, http.StatusCreated, "booking", booking)
 }
 
-func (s *Server) confirmBooking(c echo.Context) error {
-	const op = "server.confirmBooking"
-	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+// setAvailabilityHeaders lets clients update availability after a booking
+// without a follow-up GET.
+func (s *Server) setAvailabilityHeaders(c echo.Context, left models.SeatAvailability) {
+	if !s.isAdmin(c) && left.Low() {
+		c.Response().Header().Set("X-Low-Availability", "true")
+	} else {
+		c.Response().Header().Set("X-Available-Seats", strconv.FormatInt(left.Available, 10))
+	}
+	c.Response().Header().Set("X-Event-Sold-Out", strconv.FormatBool(left.Available == 0))
+}
+
+func (s *Server) bookGroup(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.bookGroup"))
 
 	eventID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		log.Printf("[%s] %s: Invalid event ID parameter: %s from IP: %s", requestID, op, c.Param("id"), c.RealIP())
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
 	}
 
-	log.Printf("[%s] %s: Starting booking confirmation for event ID: %d from IP: %s", requestID, op, eventID, c.RealIP())
+	logger.Info("Starting group booking", slog.Int("event_id", eventID))
 
 	var request struct {
-		UserName string `json:"user_name"`
+		Members []models.GroupMember `json:"members"`
+		// All or nothing unless explicitly turned off
+		RequireAll *bool `json:"require_all"`
 	}
 	if err := c.Bind(&request); err != nil {
-		log.Printf("[%s] %s: Failed to bind confirmation request data: %v", requestID, op, err)
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+		logger.Warn("Failed to bind group booking request data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid booking data")
 	}
 
-	log.Printf("[%s] %s: Confirming booking for user: %s, event ID: %d", requestID, op, request.UserName, eventID)
+	if len(request.Members) == 0 {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "members must not be empty")
+	}
+	for i, m := range request.Members {
+		if m.UserName == "" || m.Seats <= 0 {
+			logger.Warn("Invalid group member", slog.Int("index", i))
+			return echo.NewHTTPError(http.StatusUnprocessableEntity,
+				fmt.Sprintf("member %d must have a user_name and a positive number of seats", i))
+		}
+		if m.Seats > maxSeatCount {
+			logger.Warn("Invalid group member", slog.Int("index", i))
+			return echo.NewHTTPError(http.StatusUnprocessableEntity,
+				fmt.Sprintf("member %d must book at most %d seats", i, maxSeatCount))
+		}
+	}
 
-	ctx := context.Background()
-	if err := s.storage.ConfirmBooking(ctx, eventID, request.UserName); err != nil {
-		log.Printf("[%s] %s: Failed to confirm booking for user %s, event %d: %v", requestID, op, request.UserName, eventID, err)
-		if err.Error() == "storage.ConfirmBooking: booking not found" {
-			return echo.NewHTTPError(http.StatusNotFound, "Booking not found or already confirmed")
+	requireAll := request.RequireAll == nil || *request.RequireAll
+
+	ctx := dbContext(c)
+	bookings, err := s.storage.BookSeatsGroup(ctx, eventID, request.Members, requireAll)
+	if err != nil {
+		logger.Error("Failed to book seats for group", slog.Int("event_id", eventID), slog.Any("error", err))
+		if errors.Is(err, storage.ErrEventNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Event not found")
 		}
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to confirm booking")
+		if errors.Is(err, storage.ErrNotEnoughSeats) {
+			return notEnoughSeatsHTTPError(err, s.isAdmin(c))
+		}
+		if errors.Is(err, storage.ErrInvalidSeatType) {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Events with seat types must be booked per seat type")
+		}
+		if errors.Is(err, storage.ErrDuplicateBooking) {
+			return echo.NewHTTPError(http.StatusConflict, "A member already has a booking for this event")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to book seats")
 	}
 
-	log.Printf("[%s] %s: Successfully confirmed booking for user: %s, event ID: %d", requestID, op, request.UserName, eventID)
-	return c.JSON(http.StatusOK, map[string]string{"status": "confirmed"})
+	// Bookings come back in member order, so whatever doesn't line up was left out
+	var skipped []models.GroupMember
+	next := 0
+	for _, m := range request.Members {
+		if next < len(bookings) && bookings[next].UserName == m.UserName && bookings[next].Seats == m.Seats {
+			next++
+			continue
+		}
+		skipped = append(skipped, m)
+	}
+
+	logger.Info("Successfully created group booking",
+		slog.Int("event_id", eventID),
+		slog.Int("bookings", len(bookings)),
+		slog.Int("skipped", len(skipped)))
+	if len(bookings) > 0 {
+		s.availability.publish(eventID)
+		s.metrics.bookingOutcomes.Add(float64(len(bookings)), outcomeCreated)
+	}
+	response := struct {
+		Bookings []models.Booking     `json:"bookings" xml:"booking"`
+		Skipped  []models.GroupMember `json:"skipped,omitempty" xml:"skipped>member,omitempty"`
+	}{
+		Bookings: bookings,
+		Skipped:  skipped,
+	}
+	return render(c, http.StatusCreated, "bookings", response)
 }
 
-func (s *Server) getEvent(c echo.Context) error {
-	const op = "server.getEvent"
-	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+func (s *Server) confirmBooking(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.confirmBooking"))
 
 	eventID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		log.Printf("[%s] %s: Invalid event ID parameter: %s from IP: %s", requestID, op, c.Param("id"), c.RealIP())
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
 		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
 	}
 
-	log.Printf("[%s] %s: Getting event details for ID: %d from IP: %s", requestID, op, eventID, c.RealIP())
+	logger.Info("Starting booking confirmation", slog.Int("event_id", eventID))
 
-	ctx := context.Background()
-	event, err := s.storage.GetEvent(ctx, eventID)
-	if err != nil {
-		log.Printf("[%s] %s: Failed to get event ID %d: %v", requestID, op, eventID, err)
-		return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+	var request struct {
+		UserName     string `json:"user_name"`
+		ConfirmToken string `json:"confirm_token"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Failed to bind confirmation request data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if request.UserName == "" {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "user_name is required")
+	}
+	if request.ConfirmToken == "" {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "confirm_token is required")
 	}
 
-	bookings, err := s.storage.GetEventBookings(ctx, eventID)
+	ctx, err := ifMatchContext(c)
 	if err != nil {
-		log.Printf("[%s] %s: Failed to get bookings for event ID %d: %v", requestID, op, eventID, err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get event bookings")
+		return err
 	}
 
-	availableSeats, err := s.storage.GetAvailableSeats(ctx, eventID)
+	logger.Info("Confirming booking", slog.String("user_name", request.UserName), slog.Int("event_id", eventID))
+
+	bookingID, err := s.storage.ConfirmBookingWithID(ctx, eventID, request.UserName, request.ConfirmToken)
 	if err != nil {
-		log.Printf("[%s] %s: Failed to get available seats for event ID %d: %v", requestID, op, eventID, err)
-		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get available seats")
+		logger.Error("Failed to confirm booking",
+			slog.String("user_name", request.UserName), slog.Int("event_id", eventID), slog.Any("error", err))
+		if errors.Is(err, storage.ErrVersionMismatch) {
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "Booking was changed since it was read")
+		}
+		if errors.Is(err, storage.ErrInvalidToken) {
+			return echo.NewHTTPError(http.StatusForbidden, "Invalid confirm token")
+		}
+		if errors.Is(err, storage.ErrBookingNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found or already confirmed")
+		}
+		if errors.Is(err, storage.ErrBookingExpired) {
+			return echo.NewHTTPError(http.StatusGone, "Booking hold has expired, please book again")
+		}
+		if errors.Is(err, storage.ErrNotEnoughSeats) {
+			return echo.NewHTTPError(http.StatusConflict, "Not enough available seats")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to confirm booking")
 	}
 
+	logger.Info("Successfully confirmed booking", slog.String("user_name", request.UserName), slog.Int("event_id", eventID))
+	s.availability.publish(eventID)
+	s.metrics.bookingOutcomes.Inc(outcomeConfirmed)
+	s.notifyConfirmation(c, bookingID, eventID, request.UserName)
 	response := struct {
-		Event          *models.Event    `json:"event"`
-		Bookings       []models.Booking `json:"bookings"`
-		AvailableSeats int              `json:"available_seats"`
+		Status string `json:"status" xml:"status"`
 	}{
-		Event:          event,
-		Bookings:       bookings,
-		AvailableSeats: availableSeats,
+		Status: "confirmed",
 	}
-
-	log.Printf("[%s] %s: Successfully returned event details for ID: %d with %d bookings and %d available seats",
-		requestID, op, eventID, len(bookings), availableSeats)
-	return c.JSON(http.StatusOK, response)
+	return render(c, http.StatusOK, "confirmation", response)
 }
 
-func (s *Server) StartBackgroundWorker(ctx context.Context) {
-	log.Printf("Starting background worker for expired booking cleanup")
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
+func (s *Server) confirmPartial(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.confirmPartial"))
 
-	for {
-		select {
-		case <-ticker.C:
-			log.Printf("Running expired bookings cleanup...")
-			if err := s.storage.CancelExpiredBookings(ctx); err != nil {
-				log.Printf("Error during expired bookings cleanup: %v", err)
-			} else {
-				log.Printf("Expired bookings cleanup completed successfully")
-			}
-		case <-ctx.Done():
-			log.Printf("Background worker shutting down")
-			return
-		}
-	}
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+
+	var request struct {
+		UserName     string `json:"user_name"`
+		ConfirmToken string `json:"confirm_token"`
+		Seats        int    `json:"seats"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Failed to bind partial confirmation request data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if request.UserName == "" {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "user_name is required")
+	}
+	if request.ConfirmToken == "" {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "confirm_token is required")
+	}
+	if request.Seats <= 0 {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "seats must be positive")
+	}
+	if request.Seats > maxSeatCount {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, fmt.Sprintf("seats must be at most %d", maxSeatCount))
+	}
+
+	ctx, err := ifMatchContext(c)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Confirming part of booking",
+		slog.String("user_name", request.UserName),
+		slog.Int("event_id", eventID),
+		slog.Int("seats", request.Seats))
+
+	booking, err := s.storage.ConfirmPartial(ctx, eventID, request.UserName, request.ConfirmToken, request.Seats)
+	if err != nil {
+		logger.Error("Failed to confirm part of booking",
+			slog.String("user_name", request.UserName), slog.Int("event_id", eventID), slog.Any("error", err))
+		switch {
+		case errors.Is(err, storage.ErrVersionMismatch):
+			return echo.NewHTTPError(http.StatusPreconditionFailed, "Booking was changed since it was read")
+		case errors.Is(err, storage.ErrInvalidToken):
+			return echo.NewHTTPError(http.StatusForbidden, "Invalid confirm token")
+		case errors.Is(err, storage.ErrBookingNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found or already confirmed")
+		case errors.Is(err, storage.ErrBookingExpired):
+			return echo.NewHTTPError(http.StatusGone, "Booking hold has expired, please book again")
+		case errors.Is(err, storage.ErrSeatsExceedHold):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Cannot confirm more seats than held")
+		case errors.Is(err, storage.ErrNotEnoughSeats):
+			return echo.NewHTTPError(http.StatusConflict, "Not enough available seats")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to confirm booking")
+	}
+
+	logger.Info("Successfully confirmed part of booking",
+		slog.Int("booking_id", booking.ID),
+		slog.Int("seats", booking.Seats))
+	s.availability.publish(eventID)
+	s.metrics.bookingOutcomes.Inc(outcomeConfirmed)
+	s.notifyConfirmation(c, booking.ID, eventID, booking.UserName)
+	setBookingETag(c, booking)
+	return render(c, http.StatusOK, "booking", booking)
+}
+
+func (s *Server) getEvent(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getEvent"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+
+	logger.Info("Getting event details", slog.Int("event_id", eventID))
+
+	ctx := dbContext(c)
+	event, err := s.storage.GetEvent(ctx, eventID)
+	if err != nil {
+		logger.Warn("Failed to get event", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+	}
+
+	bookings, err := s.storage.GetEventBookings(ctx, eventID)
+	if err != nil {
+		logger.Error("Failed to get bookings", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get event bookings")
+	}
+
+	availableSeats, err := s.storage.GetAvailableSeats(ctx, eventID)
+	if err != nil {
+		logger.Error("Failed to get available seats", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get available seats")
+	}
+
+	seatTypes, err := s.storage.GetSeatTypeAvailability(ctx, eventID)
+	if err != nil {
+		logger.Error("Failed to get seat types", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get available seats")
+	}
+
+	response := struct {
+		Event           *models.Event                 `json:"event" xml:"event"`
+		Bookings        []models.Booking              `json:"bookings" xml:"bookings>booking"`
+		AvailableSeats  *int64                        `json:"available_seats,omitempty" xml:"available_seats,omitempty"`
+		LowAvailability bool                          `json:"low_availability,omitempty" xml:"low_availability,omitempty"`
+		SeatTypes       []models.SeatTypeAvailability `json:"seat_types,omitempty" xml:"seat_types>seat_type,omitempty"`
+	}{
+		Event:    event,
+		Bookings: bookings,
+	}
+	if !s.isAdmin(c) && event.LowAvailability(availableSeats) {
+		// Seat types stay listed so they can still be booked, just without counts
+		response.LowAvailability = true
+		for _, st := range seatTypes {
+			event.SeatTypes = append(event.SeatTypes, st.SeatType)
+		}
+	} else {
+		response.AvailableSeats = &availableSeats
+		response.SeatTypes = seatTypes
+	}
+
+	setEventCacheHeaders(c, event, availableSeats)
+
+	logger.Info("Successfully returned event details",
+		slog.Int("event_id", eventID),
+		slog.Int("bookings", len(bookings)),
+		slog.Int64("available_seats", availableSeats))
+	return render(c, http.StatusOK, "event_details", response)
+}
+
+func (s *Server) patchEvent(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.patchEvent"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+
+	var patch models.EventPatch
+	if err := c.Bind(&patch); err != nil {
+		logger.Warn("Failed to bind event patch", slog.Any("error", err))
+		if errors.Is(err, models.ErrDateOutOfRange) || errors.Is(err, models.ErrInvalidTimezone) {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, errors.Unwrap(err).Error())
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+
+	if err := s.validateEventPatch(&patch); err != nil {
+		logger.Warn("Event patch validation failed", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+
+	logger.Info("Patching event", slog.Int("event_id", eventID))
+
+	ctx := dbContext(c)
+	event, err := s.storage.PatchEvent(ctx, eventID, patch)
+	if err != nil {
+		logger.Error("Failed to patch event", slog.Int("event_id", eventID), slog.Any("error", err))
+		switch {
+		case errors.Is(err, storage.ErrEventNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+		case errors.Is(err, storage.ErrSeatsBelowTaken):
+			return echo.NewHTTPError(http.StatusConflict, "total_seats is below the number of confirmed seats")
+		case errors.Is(err, storage.ErrSeatTypeTotals):
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "total_seats must equal the sum of the event's seat type totals")
+		}
+		if httpErr := constraintHTTPError(err); httpErr != nil {
+			return httpErr
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update event")
+	}
+
+	logger.Info("Successfully patched event", slog.Int("event_id", eventID))
+	s.availability.publish(eventID)
+	return render(c, http.StatusOK, "event", event)
+}
+
+func (s *Server) rescheduleEvent(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.rescheduleEvent"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+
+	var request struct {
+		Date                  string `json:"date"`
+		RequireReconfirmation bool   `json:"require_reconfirmation"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Failed to bind reschedule request", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if request.Date == "" {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "date is required")
+	}
+
+	organizerID := organizerFrom(c)
+
+	ctx := dbContext(c)
+	event, err := s.storage.GetEvent(ctx, eventID)
+	if err != nil {
+		logger.Warn("Failed to get event", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+	}
+	if event.OrganizerID == nil || *event.OrganizerID != organizerID {
+		logger.Warn("Organizer does not own the event",
+			slog.Int("organizer_id", organizerID), slog.Int("event_id", event.ID))
+		return echo.NewHTTPError(http.StatusForbidden, "Event belongs to another organizer")
+	}
+
+	// A local wall clock means local to the event, as on creation
+	loc, err := models.LoadTimezone(event.Timezone)
+	if err != nil {
+		logger.Error("Stored event timezone is invalid", slog.String("timezone", event.Timezone), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reschedule event")
+	}
+	date, err := models.ParseEventDateIn(request.Date, loc)
+	if err != nil {
+		logger.Warn("Invalid reschedule date", slog.String("date", request.Date), slog.Any("error", err))
+		if errors.Is(err, models.ErrDateOutOfRange) {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid date")
+	}
+	if !date.After(time.Now()) {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "date must be in the future")
+	}
+
+	logger.Info("Rescheduling event",
+		slog.Int("event_id", eventID),
+		slog.Time("date", date),
+		slog.Bool("require_reconfirmation", request.RequireReconfirmation))
+
+	event, bookings, err := s.storage.RescheduleEvent(ctx, eventID, date, request.RequireReconfirmation)
+	if err != nil {
+		logger.Error("Failed to reschedule event", slog.Int("event_id", eventID), slog.Any("error", err))
+		if errors.Is(err, storage.ErrEventNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to reschedule event")
+	}
+
+	s.availability.publish(eventID)
+
+	// The new date is committed; a failed notification is logged rather than undoing it
+	notifyCtx := requestContext(c)
+	notified := 0
+	for _, b := range bookings {
+		if err := s.notifier.NotifyReschedule(notifyCtx, *event, b); err != nil {
+			logger.Error("Failed to notify booking", slog.Int("booking_id", b.ID), slog.Any("error", err))
+			continue
+		}
+		notified++
+	}
+
+	response := struct {
+		Event    *models.Event `json:"event" xml:"event"`
+		Affected int           `json:"affected_bookings" xml:"affected_bookings"`
+		Notified int           `json:"notified_bookings" xml:"notified_bookings"`
+	}{
+		Event:    event,
+		Affected: len(bookings),
+		Notified: notified,
+	}
+
+	logger.Info("Successfully rescheduled event",
+		slog.Int("event_id", eventID),
+		slog.Int("affected", len(bookings)),
+		slog.Int("notified", notified))
+	return render(c, http.StatusOK, "reschedule", response)
+}
+
+func (s *Server) headEvent(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.headEvent"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return c.NoContent(http.StatusBadRequest)
+	}
+
+	ctx := dbContext(c)
+	event, err := s.storage.GetEvent(ctx, eventID)
+	if err != nil {
+		logger.Warn("Failed to get event", slog.Int("event_id", eventID), slog.Any("error", err))
+		return c.NoContent(http.StatusNotFound)
+	}
+
+	availableSeats, err := s.storage.GetAvailableSeats(ctx, eventID)
+	if err != nil {
+		logger.Error("Failed to get available seats", slog.Int("event_id", eventID), slog.Any("error", err))
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	setEventCacheHeaders(c, event, availableSeats)
+	if !s.isAdmin(c) && event.LowAvailability(availableSeats) {
+		c.Response().Header().Set("X-Low-Availability", "true")
+	} else {
+		c.Response().Header().Set("X-Available-Seats", strconv.FormatInt(availableSeats, 10))
+	}
+
+	logger.Info("Returned event availability headers",
+		slog.Int("event_id", eventID),
+		slog.Int64("available_seats", availableSeats))
+	return c.NoContent(http.StatusOK)
+}
+
+// setEventCacheHeaders sets an ETag that changes whenever the event or its
+// availability changes, and Last-Modified from the event creation time.
+func setEventCacheHeaders(c echo.Context, event *models.Event, availableSeats int64) {
+	// No deadline hashes differently from any real one
+	deadline := -1
+	if event.CancellationDeadlineHours != nil {
+		deadline = *event.CancellationDeadlineHours
+	}
+	h := sha1.New()
+	fmt.Fprintf(h, "%d|%d|%s|%s|%d|%d|%d|%d|%t|%d|%d", event.ID, event.CreatedAt.UnixNano(), event.Date.UTC().Format(time.RFC3339Nano),
+		event.Timezone, event.TotalSeats, event.PaymentTime, event.GraceMinutes, event.HideExactBelow, event.OneBookingPerUser,
+		deadline, availableSeats)
+	c.Response().Header().Set("ETag", `W/"`+hex.EncodeToString(h.Sum(nil))[:16]+`"`)
+	c.Response().Header().Set(echo.HeaderLastModified, event.CreatedAt.UTC().Format(http.TimeFormat))
+}
+
+func (s *Server) getEventBookings(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getEventBookings"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+
+	var from, to time.Time
+	if raw := c.QueryParam("from"); raw != "" {
+		if from, _, err = parseTimeParam(raw); err != nil {
+			logger.Warn("Invalid from parameter", slog.String("from", raw))
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid from")
+		}
+	}
+	if raw := c.QueryParam("to"); raw != "" {
+		var dateOnly bool
+		if to, dateOnly, err = parseTimeParam(raw); err != nil {
+			logger.Warn("Invalid to parameter", slog.String("to", raw))
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid to")
+		}
+		// A bare date includes the whole day
+		if dateOnly {
+			to = to.AddDate(0, 0, 1).Add(-time.Microsecond)
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return echo.NewHTTPError(http.StatusBadRequest, "to must not be before from")
+	}
+
+	status := models.BookingStatus(c.QueryParam("status"))
+	if status != "" && !status.Valid() {
+		logger.Warn("Invalid status parameter", slog.String("status", string(status)))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid status")
+	}
+
+	logger.Info("Getting event bookings",
+		slog.Int("event_id", eventID),
+		slog.Time("from", from),
+		slog.Time("to", to),
+		slog.String("status", string(status)))
+
+	// Paging parameters switch the endpoint to cursor mode, which pages by booking ID
+	if c.QueryParam("cursor") != "" || c.QueryParam("limit") != "" {
+		if !from.IsZero() || !to.IsZero() || status != "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "cursor pagination can't be combined with from, to or status")
+		}
+		return s.listEventBookingsPage(c, logger, eventID)
+	}
+
+	ctx := dbContext(c)
+	if _, err := s.storage.GetEvent(ctx, eventID); err != nil {
+		logger.Warn("Failed to get event", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+	}
+
+	bookings, err := s.storage.GetEventBookingsInRange(ctx, eventID, from, to, status)
+	if err != nil {
+		logger.Error("Failed to get event bookings", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get event bookings")
+	}
+
+	response := struct {
+		Bookings []models.Booking `json:"bookings" xml:"bookings>booking"`
+		Count    int              `json:"count" xml:"count"`
+	}{
+		Bookings: bookings,
+		Count:    len(bookings),
+	}
+
+	logger.Info("Successfully returned event bookings",
+		slog.Int("event_id", eventID),
+		slog.Int("count", len(bookings)))
+	return render(c, http.StatusOK, "event_bookings", response)
+}
+
+func (s *Server) listEventBookingsPage(c echo.Context, logger *slog.Logger, eventID int) error {
+	page, err := s.parsePagination(c)
+	if err != nil {
+		return err
+	}
+	limit := page.Limit
+
+	afterID := 0
+	if raw := c.QueryParam("cursor"); raw != "" {
+		var err error
+		if afterID, err = decodeBookingCursor(raw); err != nil {
+			logger.Warn("Invalid cursor parameter", slog.String("cursor", raw), slog.Any("error", err))
+			return echo.NewHTTPError(http.StatusBadRequest, "invalid cursor")
+		}
+	}
+
+	logger.Info("Getting event bookings page",
+		slog.Int("event_id", eventID),
+		slog.Int("limit", limit),
+		slog.Int("after_id", afterID))
+
+	ctx := dbContext(c)
+	if _, err := s.storage.GetEvent(ctx, eventID); err != nil {
+		logger.Warn("Failed to get event", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+	}
+
+	bookings, next, err := s.storage.GetEventBookingsAfter(ctx, eventID, afterID, limit)
+	if err != nil {
+		logger.Error("Failed to get event bookings page", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get event bookings")
+	}
+
+	response := struct {
+		Bookings []models.Booking `json:"bookings" xml:"bookings>booking"`
+		Next     string           `json:"next,omitempty" xml:"next,omitempty"`
+		AsOf     time.Time        `json:"as_of" xml:"as_of"`
+	}{
+		Bookings: bookings,
+		AsOf:     serverTimeFrom(c),
+	}
+	if next != 0 {
+		response.Next = encodeBookingCursor(next)
+	}
+
+	logger.Info("Successfully returned event bookings page",
+		slog.Int("event_id", eventID),
+		slog.Int("count", len(bookings)),
+		slog.Bool("has_next", next != 0))
+	return render(c, http.StatusOK, "event_bookings", response)
+}
+
+func (s *Server) getBookingForUser(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getBookingForUser"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+
+	userName := c.QueryParam("name")
+	if userName == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "name is required")
+	}
+
+	logger.Info("Getting booking for user", slog.Int("event_id", eventID), slog.String("user_name", userName))
+
+	ctx := dbContext(c)
+	booking, err := s.storage.GetBookingForUser(ctx, eventID, userName)
+	if err != nil {
+		if errors.Is(err, storage.ErrBookingNotFound) {
+			logger.Warn("Booking not found", slog.Int("event_id", eventID), slog.String("user_name", userName))
+			return echo.NewHTTPError(http.StatusNotFound, "Booking not found")
+		}
+		logger.Error("Failed to get booking for user", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get booking")
+	}
+
+	logger.Info("Successfully returned booking for user", slog.Int("booking_id", booking.ID))
+	setBookingETag(c, booking)
+	return render(c, http.StatusOK, "booking", booking)
+}
+
+func (s *Server) getUtilization(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getUtilization"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+
+	bucket := c.QueryParam("bucket")
+	switch bucket {
+	case "":
+		bucket = storage.UtilizationBucketHour
+	case storage.UtilizationBucketHour, storage.UtilizationBucketDay, storage.UtilizationBucketWeek:
+	default:
+		logger.Warn("Invalid bucket parameter", slog.String("bucket", bucket))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid bucket")
+	}
+
+	logger.Info("Getting event utilization", slog.Int("event_id", eventID), slog.String("bucket", bucket))
+
+	ctx := dbContext(c)
+	if _, err := s.storage.GetEvent(ctx, eventID); err != nil {
+		logger.Warn("Failed to get event", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+	}
+
+	points, err := s.storage.GetUtilization(ctx, eventID, bucket)
+	if err != nil {
+		logger.Error("Failed to get utilization", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get utilization")
+	}
+
+	response := struct {
+		EventID int                       `json:"event_id" xml:"event_id"`
+		Bucket  string                    `json:"bucket" xml:"bucket"`
+		Series  []models.UtilizationPoint `json:"series" xml:"series>point"`
+	}{
+		EventID: eventID,
+		Bucket:  bucket,
+		Series:  points,
+	}
+
+	logger.Info("Successfully returned utilization", slog.Int("event_id", eventID), slog.Int("buckets", len(points)))
+	return render(c, http.StatusOK, "utilization", response)
+}
+
+func (s *Server) getUserBookings(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getUserBookings"))
+
+	userName := c.Param("name")
+
+	page, err := s.parsePagination(c)
+	if err != nil {
+		return err
+	}
+	limit, offset := page.Limit, page.Offset
+
+	status := models.BookingStatus(c.QueryParam("status"))
+	if status != "" && !status.Valid() {
+		logger.Warn("Invalid status parameter", slog.String("status", string(status)))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid status")
+	}
+
+	logger.Info("Getting user bookings",
+		slog.String("user_name", userName),
+		slog.String("status", string(status)),
+		slog.Int("limit", limit),
+		slog.Int("offset", offset))
+
+	ctx := dbContext(c)
+	bookings, total, err := s.storage.GetUserBookings(ctx, userName, status, limit, offset)
+	if err != nil {
+		logger.Error("Failed to get user bookings", slog.String("user_name", userName), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get user bookings")
+	}
+
+	response := struct {
+		Bookings []models.Booking `json:"bookings" xml:"bookings>booking"`
+		Total    int              `json:"total" xml:"total"`
+		Limit    int              `json:"limit" xml:"limit"`
+		Offset   int              `json:"offset" xml:"offset"`
+		AsOf     time.Time        `json:"as_of" xml:"as_of"`
+	}{
+		Bookings: bookings,
+		Total:    total,
+		Limit:    limit,
+		Offset:   offset,
+		AsOf:     serverTimeFrom(c),
+	}
+
+	logger.Info("Successfully returned user bookings",
+		slog.String("user_name", userName),
+		slog.Int("count", len(bookings)),
+		slog.Int("total", total))
+	return render(c, http.StatusOK, "user_bookings", response)
+}
+
+// getUnbookedEvents lists upcoming events the user hasn't booked yet, for
+// recommendations.
+func (s *Server) getUnbookedEvents(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getUnbookedEvents"))
+
+	userName := c.Param("name")
+
+	page, err := s.parsePagination(c)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Getting unbooked events",
+		slog.String("user_name", userName),
+		slog.Int("limit", page.Limit),
+		slog.Int("offset", page.Offset))
+
+	ctx := dbContext(c)
+	events, total, err := s.storage.GetUnbookedEvents(ctx, userName, page.Limit, page.Offset)
+	if err != nil {
+		logger.Error("Failed to get unbooked events", slog.String("user_name", userName), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get events")
+	}
+
+	eventsWithSeats, err := s.withAvailableSeats(ctx, events, s.isAdmin(c))
+	if err != nil {
+		logger.Error("Failed to get available seats", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get available seats")
+	}
+
+	response := struct {
+		Events []EventWithAvailableSeats `json:"events" xml:"events>event"`
+		Total  int                       `json:"total" xml:"total"`
+		Limit  int                       `json:"limit" xml:"limit"`
+		Offset int                       `json:"offset" xml:"offset"`
+		AsOf   time.Time                 `json:"as_of" xml:"as_of"`
+	}{
+		Events: eventsWithSeats,
+		Total:  total,
+		Limit:  page.Limit,
+		Offset: page.Offset,
+		AsOf:   serverTimeFrom(c),
+	}
+
+	logger.Info("Successfully returned unbooked events",
+		slog.String("user_name", userName),
+		slog.Int("count", len(events)),
+		slog.Int("total", total))
+	return render(c, http.StatusOK, "unbooked_events", response)
+}
+
+func (s *Server) getBookingStatuses(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getBookingStatuses"))
+
+	var request struct {
+		IDs []int `json:"ids"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Invalid booking status request body", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request body")
+	}
+
+	if len(request.IDs) == 0 {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "ids must not be empty")
+	}
+	if len(request.IDs) > maxBatchIDs {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, fmt.Sprintf("at most %d ids are allowed", maxBatchIDs))
+	}
+
+	logger.Info("Getting booking statuses", slog.Int("count", len(request.IDs)))
+
+	ctx := dbContext(c)
+	states, err := s.storage.GetBookingStates(ctx, request.IDs)
+	if err != nil {
+		logger.Error("Failed to get booking statuses", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get booking statuses")
+	}
+
+	found := make(map[int]bool, len(states))
+	for _, state := range states {
+		found[state.ID] = true
+	}
+	missing := []int{}
+	for _, id := range request.IDs {
+		if !found[id] && !slices.Contains(missing, id) {
+			missing = append(missing, id)
+		}
+	}
+
+	response := struct {
+		Bookings []models.BookingState `json:"bookings" xml:"bookings>booking"`
+		Missing  []int                 `json:"missing" xml:"missing>id"`
+	}{
+		Bookings: states,
+		Missing:  missing,
+	}
+
+	logger.Info("Successfully returned booking statuses",
+		slog.Int("found", len(states)),
+		slog.Int("missing", len(missing)))
+	return render(c, http.StatusOK, "booking_statuses", response)
+}
+
+func (s *Server) StartBackgroundWorker(ctx context.Context) {
+	s.logger.Info("Starting background worker for expired booking cleanup")
+
+	// Bookings that expired while the service was down shouldn't wait a full interval
+	if ctx.Err() != nil {
+		s.logger.Info("Background worker shutting down")
+		return
+	}
+	s.runCleanup(ctx)
+
+	ticker := time.NewTicker(s.workerInterval)
+	defer ticker.Stop()
+	reservations := time.NewTicker(s.reservationInterval)
+	defer reservations.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.runCleanup(ctx)
+			err := s.RefreshAvailabilityGauge(ctx)
+			s.metrics.observeWorkerRun(taskAvailabilityGauge, err)
+			if err != nil {
+				s.logger.Error("Failed to refresh availability gauge", slog.Any("error", err))
+			}
+		case <-reservations.C:
+			s.expireReservations(ctx)
+		case <-ctx.Done():
+			s.logger.Info("Background worker shutting down")
+			return
+		}
+	}
+}
+
+func (s *Server) runCleanup(ctx context.Context) {
+	if s.readOnly {
+		s.logger.Debug("Read-only mode, skipping expired bookings cleanup")
+		return
+	}
+	s.logger.Info("Running expired bookings cleanup...")
+	eventIDs, cancelled, err := s.storage.CancelExpiredBookings(ctx)
+	s.recordCleanup(err)
+	if err != nil {
+		s.logger.Error("Error during expired bookings cleanup",
+			slog.Int64("consecutive_failures", s.cleanupFailures.Load()), slog.Any("error", err))
+		return
+	}
+
+	s.logger.Info("Expired bookings cleanup completed successfully",
+		slog.Any("event_ids", eventIDs), slog.Int64("cancelled", cancelled))
+	s.availability.publish(eventIDs...)
+	s.metrics.bookingOutcomes.Add(float64(cancelled), outcomeExpired)
+}
+
+// recordCleanup tracks the outcome of a cleanup run for the health endpoints
+// and metrics.
+func (s *Server) recordCleanup(err error) {
+	s.metrics.observeWorkerRun(taskCleanup, err)
+	if err != nil {
+		s.lastCleanupError.Store(&workerError{Message: err.Error(), At: time.Now().UTC()})
+		s.cleanupFailures.Add(1)
+		return
+	}
+	s.cleanupFailures.Store(0)
+	s.lastCleanupError.Store(nil)
+	s.lastCleanup.Store(time.Now().UnixNano())
+}
+
+// readyz reports degraded once cleanup has failed cleanupFailureThreshold
+// times in a row, since bookings then stop expiring while requests still work.
+func (s *Server) readyz(c echo.Context) error {
+	response := struct {
+		Status              string `json:"status" xml:"status"`
+		ConsecutiveFailures int64  `json:"worker_consecutive_failures" xml:"worker_consecutive_failures"`
+		LastError           string `json:"worker_last_error,omitempty" xml:"worker_last_error,omitempty"`
+	}{
+		Status:              "ok",
+		ConsecutiveFailures: s.cleanupFailures.Load(),
+	}
+	if last := s.lastCleanupError.Load(); last != nil {
+		response.LastError = last.Message
+	}
+
+	if response.ConsecutiveFailures >= cleanupFailureThreshold {
+		response.Status = "degraded"
+		loggerFrom(c).Warn("Background worker keeps failing",
+			slog.Int64("consecutive_failures", response.ConsecutiveFailures),
+			slog.String("last_error", response.LastError))
+		return render(c, http.StatusServiceUnavailable, "readiness", response)
+	}
+
+	return render(c, http.StatusOK, "readiness", response)
+}
+
+func (s *Server) healthz(c echo.Context) error {
+	response := struct {
+		Status            string     `json:"status"`
+		WorkerLastSuccess *time.Time `json:"worker_last_success"`
+	}{
+		Status: "ok",
+	}
+
+	// Before the first run, measure staleness from server start
+	reference := s.startedAt
+	if nanos := s.lastCleanup.Load(); nanos != 0 {
+		last := time.Unix(0, nanos).UTC()
+		response.WorkerLastSuccess = &last
+		reference = last
+	}
+
+	// A read-only instance never cleans up, so there is nothing to go stale
+	if !s.readOnly && time.Since(reference) > 3*s.workerInterval {
+		response.Status = "degraded"
+		loggerFrom(c).Warn("Background worker is stale", slog.Time("since", reference))
+		return c.JSON(http.StatusServiceUnavailable, response)
+	}
+
+	return c.JSON(http.StatusOK, response)
 }
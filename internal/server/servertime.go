@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const serverTimeContextKey = "server_time"
+
+// stampServerTime records when the request was received and sends it back in
+// the Date and X-Server-Time headers, so clients combining several responses
+// can tell which is fresher. Paginated envelopes repeat it as as_of.
+func stampServerTime(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		now := time.Now().UTC()
+		c.Set(serverTimeContextKey, now)
+		header := c.Response().Header()
+		header.Set("Date", now.Format(http.TimeFormat))
+		header.Set("X-Server-Time", now.Format(time.RFC3339Nano))
+		return next(c)
+	}
+}
+
+// serverTimeFrom returns the time stored by stampServerTime, or the current
+// time for contexts that did not pass through it.
+func serverTimeFrom(c echo.Context) time.Time {
+	if now, ok := c.Get(serverTimeContextKey).(time.Time); ok {
+		return now
+	}
+	return time.Now().UTC()
+}
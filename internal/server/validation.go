@@ -0,0 +1,56 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// FieldError is a single rejected field of a request body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects every invalid field so clients can fix them in
+// one round trip.
+type ValidationErrors []FieldError
+
+func (v *ValidationErrors) add(field, format string, args ...any) {
+	*v = append(*v, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// err returns nil when nothing was collected, so callers never see a typed nil.
+func (v ValidationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, len(v))
+	for i, fe := range v {
+		messages[i] = fe.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// validationHTTPError renders ValidationErrors as a 422 listing every field,
+// keeping the joined message for clients that only read "message".
+func validationHTTPError(err error) *echo.HTTPError {
+	var fieldErrs ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+	}
+	return echo.NewHTTPError(http.StatusUnprocessableEntity, struct {
+		Message string       `json:"message"`
+		Errors  []FieldError `json:"errors"`
+	}{
+		Message: fieldErrs.Error(),
+		Errors:  fieldErrs,
+	})
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"L3_5/internal/storage"
+
+	"github.com/labstack/echo/v4"
+)
+
+// joinWaitlist puts a user at the end of an event's waitlist.
+func (s *Server) joinWaitlist(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.joinWaitlist"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+
+	var request struct {
+		UserName string `json:"user_name"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.Warn("Failed to bind waitlist request data", slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid request data")
+	}
+	if request.UserName == "" {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "user_name is required")
+	}
+
+	logger.Info("Joining waitlist", slog.Int("event_id", eventID), slog.String("user_name", request.UserName))
+
+	ctx := dbContext(c)
+	entry, err := s.storage.JoinWaitlist(ctx, eventID, request.UserName)
+	if err != nil {
+		logger.Warn("Failed to join waitlist", slog.Int("event_id", eventID), slog.Any("error", err))
+		switch {
+		case errors.Is(err, storage.ErrEventNotFound):
+			return echo.NewHTTPError(http.StatusNotFound, "Event not found")
+		case errors.Is(err, storage.ErrAlreadyWaitlisted):
+			return echo.NewHTTPError(http.StatusConflict, "User is already on the waitlist")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to join waitlist")
+	}
+
+	logger.Info("Successfully joined waitlist", slog.Int("entry_id", entry.ID), slog.Int("position", entry.Position))
+	return render(c, http.StatusCreated, "waitlist_entry", entry)
+}
+
+// getWaitlistPosition tells a user their place in an event's waitlist.
+func (s *Server) getWaitlistPosition(c echo.Context) error {
+	logger := loggerFrom(c).With(slog.String("op", "server.getWaitlistPosition"))
+
+	eventID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		logger.Warn("Invalid event ID parameter", slog.String("id", c.Param("id")))
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid event ID")
+	}
+	userName := c.QueryParam("name")
+	if userName == "" {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, "name is required")
+	}
+
+	ctx := dbContext(c)
+	position, err := s.storage.GetWaitlistPosition(ctx, eventID, userName)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotWaitlisted) {
+			logger.Warn("User is not on the waitlist", slog.Int("event_id", eventID), slog.String("user_name", userName))
+			return echo.NewHTTPError(http.StatusNotFound, "User is not on the waitlist")
+		}
+		logger.Error("Failed to get waitlist position", slog.Int("event_id", eventID), slog.Any("error", err))
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to get waitlist position")
+	}
+
+	response := struct {
+		EventID  int    `json:"event_id" xml:"event_id"`
+		UserName string `json:"user_name" xml:"user_name"`
+		Position int    `json:"position" xml:"position"`
+	}{
+		EventID:  eventID,
+		UserName: userName,
+		Position: position,
+	}
+
+	logger.Info("Successfully returned waitlist position",
+		slog.Int("event_id", eventID), slog.String("user_name", userName), slog.Int("position", position))
+	return render(c, http.StatusOK, "waitlist_position", response)
+}
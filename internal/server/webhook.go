@@ -0,0 +1,100 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"L3_5/models"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Webhook payload types
+const (
+	webhookEventRescheduled = "event.rescheduled"
+	webhookBookingConfirmed = "booking.confirmed"
+)
+
+// webhookNotifier posts notifications as JSON to a configured URL. The
+// originating request ID is forwarded so receivers can correlate calls.
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+	logger *slog.Logger
+}
+
+// newNotifier returns a webhookNotifier when a webhook URL is configured and
+// a logNotifier otherwise.
+func newNotifier(cfg *models.Config, logger *slog.Logger) Notifier {
+	if cfg.Webhook.URL == "" {
+		return logNotifier{logger: logger}
+	}
+	return &webhookNotifier{
+		url:    cfg.Webhook.URL,
+		client: &http.Client{Timeout: cfg.Webhook.Timeout},
+		logger: logger,
+	}
+}
+
+type webhookPayload struct {
+	Type     string          `json:"type"`
+	EventID  int             `json:"event_id"`
+	UserName string          `json:"user_name"`
+	Event    *models.Event   `json:"event,omitempty"`
+	Booking  *models.Booking `json:"booking,omitempty"`
+}
+
+func (n *webhookNotifier) NotifyReschedule(ctx context.Context, event models.Event, booking models.Booking) error {
+	return n.post(ctx, webhookPayload{
+		Type:     webhookEventRescheduled,
+		EventID:  event.ID,
+		UserName: booking.UserName,
+		Event:    &event,
+		Booking:  &booking,
+	})
+}
+
+func (n *webhookNotifier) NotifyConfirmation(ctx context.Context, eventID int, userName string) error {
+	return n.post(ctx, webhookPayload{
+		Type:     webhookBookingConfirmed,
+		EventID:  eventID,
+		UserName: userName,
+	})
+}
+
+func (n *webhookNotifier) post(ctx context.Context, payload webhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	requestID := requestIDFrom(ctx)
+	if requestID != "" {
+		req.Header.Set(echo.HeaderXRequestID, requestID)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	n.logger.Info("Delivered webhook",
+		slog.String("type", payload.Type),
+		slog.Int("event_id", payload.EventID),
+		slog.String("request_id", requestID))
+	return nil
+}
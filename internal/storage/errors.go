@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"L3_5/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var ErrConstraintViolation = errors.New("constraint violation")
+
+// ConstraintError is a write rejected by a database constraint. Rule is a
+// message safe to show to API clients.
+type ConstraintError struct {
+	Constraint string
+	Rule       string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", ErrConstraintViolation, e.Rule, e.Constraint)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return ErrConstraintViolation
+}
+
+// ShortfallError is ErrNotEnoughSeats with the numbers behind it, so callers
+// can tell the client how many seats were missing. HideExactBelow is the
+// event's threshold, below which Available isn't for the public.
+type ShortfallError struct {
+	Requested      int64
+	Available      int64
+	HideExactBelow int
+}
+
+func (e *ShortfallError) Error() string {
+	return fmt.Sprintf("%s: requested %d, available %d", ErrNotEnoughSeats, e.Requested, e.Available)
+}
+
+func (e *ShortfallError) Unwrap() error {
+	return ErrNotEnoughSeats
+}
+
+// Low reports whether Available is below the event's HideExactBelow threshold.
+func (e *ShortfallError) Low() bool {
+	return (&models.Event{HideExactBelow: e.HideExactBelow}).LowAvailability(e.Available)
+}
+
+// Shortfall is how many more seats the request needed.
+func (e *ShortfallError) Shortfall() int64 {
+	return e.Requested - max(e.Available, 0)
+}
+
+// BatchEventError is the failure of one event of a multi-event create, at
+// position Index. The other events of the call were not created either.
+type BatchEventError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchEventError) Error() string {
+	return fmt.Sprintf("event %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchEventError) Unwrap() error {
+	return e.Err
+}
+
+// constraintRules describes the named constraints a client can trip.
+var constraintRules = map[string]string{
+	"events_total_seats_check":                 "total_seats must be positive",
+	"events_payment_time_check":                "payment_time must be positive",
+	"events_grace_minutes_check":               "grace_minutes must not be negative",
+	"events_confirmed_seats_check":             "confirmed seats must not be negative",
+	"events_hide_exact_below_check":            "hide_exact_below must not be negative",
+	"events_cancellation_deadline_hours_check": "cancellation_deadline_hours must not be negative",
+	"events_metadata_check":                    "metadata must be a JSON object",
+	"seat_types_total_check":                   "seat type total must be positive",
+	"seat_types_price_check":                   "seat type price must not be negative",
+	"seat_types_pkey":                          "seat types must be unique per event",
+	"bookings_status_check":                    "status must be pending, confirmed or cancelled",
+	eventIdentityConstraint:                    "organizer already has an event with this name and date",
+	onePerUserConstraint:                       "user already has an active booking for this event",
+	waitlistPendingConstraint:                  "user is already on the waitlist",
+}
+
+// eventIdentityConstraint makes an organizer's events unique by name and date.
+const eventIdentityConstraint = "events_organizer_name_date_key"
+
+// onePerUserConstraint allows one active booking per user on events with
+// one_booking_per_user set.
+const onePerUserConstraint = "bookings_one_per_user_key"
+
+// waitlistPendingConstraint keeps a user on an event's waitlist only once.
+const waitlistPendingConstraint = "waitlist_entries_pending_key"
+
+// isUniqueViolation reports whether err is a unique violation of constraint.
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == constraint
+}
+
+// translateConstraint turns check, not-null and unique violations into a
+// ConstraintError and returns any other error unchanged.
+func translateConstraint(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case "23514", "23505": // check_violation, unique_violation
+		rule, ok := constraintRules[pgErr.ConstraintName]
+		if !ok {
+			rule = "violates " + pgErr.ConstraintName
+		}
+		return &ConstraintError{Constraint: pgErr.ConstraintName, Rule: rule}
+	case "23502": // not_null_violation
+		return &ConstraintError{Constraint: pgErr.ColumnName + "_not_null", Rule: pgErr.ColumnName + " is required"}
+	}
+	return err
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 
 	"L3_5/models"
 
@@ -13,7 +14,7 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func InitDB(cfg *models.Config) (*pgxpool.Pool, error) {
+func InitDB(cfg *models.Config, logger *slog.Logger) (*pgxpool.Pool, error) {
 	const op = "storage.initDB"
 
 	log.Printf("%s: Initializing database connection", op)
@@ -28,7 +29,17 @@ func InitDB(cfg *models.Config) (*pgxpool.Pool, error) {
 		cfg.Database.Name,
 	)
 
-	pool, err := pgxpool.New(context.Background(), dsn)
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		log.Printf("%s: Failed to parse connection config: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	if threshold := cfg.Database.SlowQueryThreshold; threshold > 0 {
+		log.Printf("%s: Logging queries slower than %s", op, threshold)
+		poolCfg.ConnConfig.Tracer = NewSlowQueryTracer(threshold, logger)
+	}
+
+	pool, err := pgxpool.NewWithConfig(context.Background(), poolCfg)
 	if err != nil {
 		log.Printf("%s: Failed to create connection pool: %v", op, err)
 		return nil, fmt.Errorf("%s: %v", op, err)
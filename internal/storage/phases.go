@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Phases of the booking transaction reported to a PhaseObserver
+const (
+	// Getting a pooled connection, starting the transaction and locking the
+	// event row; grows when bookings queue for connections or the lock
+	PhaseAcquire = "acquire"
+	// Availability, one-per-user and seat type checks
+	PhaseCheckAvailability = "check_availability"
+	// Inserting the booking and committing
+	PhaseInsert = "insert"
+)
+
+// PhaseObserver is told how long each phase of a transaction took.
+type PhaseObserver func(phase string, elapsed time.Duration)
+
+type phaseObserverKey struct{}
+
+// WithPhaseObserver returns a context whose booking transactions report
+// their phase timings to observe.
+func WithPhaseObserver(ctx context.Context, observe PhaseObserver) context.Context {
+	return context.WithValue(ctx, phaseObserverKey{}, observe)
+}
+
+// phaseTimer measures consecutive phases, each from the end of the last.
+type phaseTimer struct {
+	observe PhaseObserver
+	last    time.Time
+}
+
+func startPhases(ctx context.Context) *phaseTimer {
+	observe, _ := ctx.Value(phaseObserverKey{}).(PhaseObserver)
+	return &phaseTimer{observe: observe, last: time.Now()}
+}
+
+// done reports the phase that just finished and starts timing the next.
+func (t *phaseTimer) done(phase string) {
+	now := time.Now()
+	if t.observe != nil {
+		t.observe(phase, now.Sub(t.last))
+	}
+	t.last = now
+}
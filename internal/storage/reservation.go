@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"L3_5/models"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// CreateReservation holds reservation.Seats of an event for ttl. The seats
+// must be free of confirmed bookings, held pending bookings and other
+// reservations. Events with seat types can't be reserved, as reservations
+// aren't typed.
+func (s *Storage) CreateReservation(ctx context.Context, reservation *models.Reservation, ttl time.Duration) error {
+	const op = "storage.CreateReservation"
+
+	log.Printf("%s: Reserving %d seats for user: %s, event ID: %d, ttl: %s",
+		op, reservation.Seats, reservation.UserName, reservation.EventID, ttl)
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The event row lock orders reservations against each other and against
+	// confirmations, which take it for the confirmed_seats counter
+	var available int64
+	var hasTypes bool
+	var hideExactBelow int
+	err = tx.QueryRow(ctx, `SELECT e.total_seats::bigint - e.confirmed_seats - `+heldPendingSeats+` - `+reservedSeats+`,
+                                   EXISTS (SELECT 1 FROM seat_types st WHERE st.event_id = e.id), e.hide_exact_below
+                            FROM events e WHERE e.id = $1 FOR UPDATE`, reservation.EventID).Scan(&available, &hasTypes, &hideExactBelow)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Event %d not found", op, reservation.EventID)
+		return fmt.Errorf("%s: %w", op, ErrEventNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to check available seats for event %d: %v", op, reservation.EventID, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	if hasTypes {
+		log.Printf("%s: Event %d has seat types, which reservations don't support", op, reservation.EventID)
+		return fmt.Errorf("%s: %w", op, ErrInvalidSeatType)
+	}
+	if available < int64(reservation.Seats) {
+		log.Printf("%s: Not enough seats - Available: %d, Requested: %d, Event: %d",
+			op, available, reservation.Seats, reservation.EventID)
+		return fmt.Errorf("%s: %w", op, &ShortfallError{Requested: int64(reservation.Seats), Available: available, HideExactBelow: hideExactBelow})
+	}
+
+	token, tokenHash, err := newConfirmToken()
+	if err != nil {
+		log.Printf("%s: Failed to generate reservation token: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+
+	err = tx.QueryRow(ctx, `INSERT INTO reservations (event_id, user_name, seats, token_hash, expires_at)
+                            VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP + $5 * interval '1 second')
+                            RETURNING id, expires_at, created_at`,
+		reservation.EventID, reservation.UserName, reservation.Seats, tokenHash, ttl.Seconds()).
+		Scan(&reservation.ID, &reservation.ExpiresAt, &reservation.CreatedAt)
+	if err != nil {
+		log.Printf("%s: Failed to insert reservation: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit reservation: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	reservation.Token = token
+
+	log.Printf("%s: Created reservation %d for user: %s, seats: %d, event: %d",
+		op, reservation.ID, reservation.UserName, reservation.Seats, reservation.EventID)
+	return nil
+}
+
+// PromoteReservation turns an unexpired reservation into a pending booking of
+// its seats, releasing the reservation in the same transaction. The booking
+// then follows the usual payment flow.
+func (s *Storage) PromoteReservation(ctx context.Context, reservationID int, token string) (*models.Booking, models.SeatAvailability, error) {
+	const op = "storage.PromoteReservation"
+
+	log.Printf("%s: Promoting reservation %d", op, reservationID)
+
+	tokenHash := hashConfirmToken(token)
+	booking := &models.Booking{}
+	err := s.retryRead(ctx, op, func() error {
+		return s.pool.QueryRow(ctx, `SELECT event_id, user_name, seats FROM reservations 
+                                     WHERE id = $1 AND token_hash = $2 AND expires_at >= NOW()`,
+			reservationID, tokenHash).Scan(&booking.EventID, &booking.UserName, &booking.Seats)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: No active reservation %d with this token", op, reservationID)
+		return nil, models.SeatAvailability{}, fmt.Errorf("%s: %w", op, ErrReservationNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to load reservation %d: %v", op, reservationID, err)
+		return nil, models.SeatAvailability{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	// Deleting it again inside the booking transaction settles a race with
+	// expiry or a second promotion
+	claim := func(tx pgx.Tx) error {
+		res, err := tx.Exec(ctx, `DELETE FROM reservations WHERE id = $1 AND token_hash = $2 AND expires_at >= NOW()`,
+			reservationID, tokenHash)
+		if err != nil {
+			log.Printf("%s: Failed to release reservation %d: %v", op, reservationID, err)
+			return fmt.Errorf("%s: %v", op, err)
+		}
+		if res.RowsAffected() == 0 {
+			log.Printf("%s: Reservation %d expired or was promoted meanwhile", op, reservationID)
+			return fmt.Errorf("%s: %w", op, ErrReservationNotFound)
+		}
+		return nil
+	}
+
+	left, err := s.bookSeats(ctx, booking, claim)
+	if err != nil {
+		return nil, models.SeatAvailability{}, err
+	}
+
+	log.Printf("%s: Promoted reservation %d to booking %d", op, reservationID, booking.ID)
+	return booking, left, nil
+}
+
+// ExpireReservations deletes reservations past their expiry, freeing their
+// seats. It returns the affected event IDs and how many it removed.
+func (s *Storage) ExpireReservations(ctx context.Context) ([]int, int64, error) {
+	const op = "storage.ExpireReservations"
+
+	rows, err := s.pool.Query(ctx, `WITH expired AS (
+                                        DELETE FROM reservations WHERE expires_at < NOW() RETURNING event_id
+                                    )
+                                    SELECT event_id, COUNT(*) FROM expired GROUP BY event_id ORDER BY event_id`)
+	if err != nil {
+		log.Printf("%s: Failed to delete expired reservations: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	var eventIDs []int
+	var expired int64
+	for rows.Next() {
+		var eventID int
+		var count int64
+		if err := rows.Scan(&eventID, &count); err != nil {
+			log.Printf("%s: Failed to scan expired reservations: %v", op, err)
+			return nil, 0, fmt.Errorf("%s: %v", op, err)
+		}
+		eventIDs = append(eventIDs, eventID)
+		expired += count
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Error iterating expired reservations: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Released %d expired reservations across %d events", op, expired, len(eventIDs))
+	return eventIDs, expired, nil
+}
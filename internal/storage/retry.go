@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Reads that could not get a connection are retried this many times in
+// total, waiting readRetryBackoff, then twice as long, between attempts.
+const (
+	readAttempts     = 3
+	readRetryBackoff = 50 * time.Millisecond
+)
+
+// isTransientAcquireError reports whether err means no connection could be
+// had rather than that the query failed: the connection could not be
+// established, or it broke before the query was sent.
+func isTransientAcquireError(err error) bool {
+	var connectErr *pgconn.ConnectError
+	return errors.As(err, &connectErr) || pgconn.SafeToRetry(err)
+}
+
+// retryRead runs read, retrying with backoff while it fails to get a
+// connection. Only reads and idempotent writes may go through it.
+func (s *Storage) retryRead(ctx context.Context, op string, read func() error) error {
+	backoff := s.readRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := read()
+		if err == nil || attempt >= s.readAttempts || !isTransientAcquireError(err) {
+			return err
+		}
+
+		log.Printf("%s: No connection on attempt %d, retrying in %s: %v", op, attempt, backoff, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// queryRead is pool.Query retried by retryRead. Errors met while reading the
+// rows are not retried.
+func (s *Storage) queryRead(ctx context.Context, op, sql string, args ...any) (pgx.Rows, error) {
+	var rows pgx.Rows
+	err := s.retryRead(ctx, op, func() error {
+		var err error
+		rows, err = s.pool.Query(ctx, sql, args...)
+		return err
+	})
+	return rows, err
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type slowQueryKey struct{}
+
+type queryStart struct {
+	sql   string
+	args  int
+	start time.Time
+}
+
+// SlowQueryTracer logs queries that take at least threshold at warn level.
+// Arguments may hold user data, so only their number is logged.
+type SlowQueryTracer struct {
+	threshold time.Duration
+	logger    *slog.Logger
+}
+
+func NewSlowQueryTracer(threshold time.Duration, logger *slog.Logger) *SlowQueryTracer {
+	return &SlowQueryTracer{threshold: threshold, logger: logger}
+}
+
+func (t *SlowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, slowQueryKey{}, queryStart{
+		sql:   data.SQL,
+		args:  len(data.Args),
+		start: time.Now(),
+	})
+}
+
+func (t *SlowQueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	query, ok := ctx.Value(slowQueryKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(query.start)
+	if elapsed < t.threshold {
+		return
+	}
+
+	attrs := []any{
+		slog.String("op", "storage.slowQuery"),
+		// Collapse the indentation of multi-line queries onto one line
+		slog.String("sql", strings.Join(strings.Fields(query.sql), " ")),
+		slog.Int("args", query.args),
+		slog.Duration("duration", elapsed),
+		slog.Duration("threshold", t.threshold),
+	}
+	if data.Err != nil {
+		attrs = append(attrs, slog.Any("error", data.Err))
+	}
+	t.logger.WarnContext(ctx, "Slow query", attrs...)
+}
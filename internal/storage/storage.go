@@ -2,20 +2,198 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
 
 	"L3_5/models"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+var (
+	ErrDuplicateEvent     = errors.New("event with the same name and date already exists")
+	ErrEventExists        = errors.New("identical event already exists")
+	ErrEventNotFound      = errors.New("event not found")
+	ErrNotEnoughSeats     = errors.New("not enough seats")
+	ErrBookingNotFound    = errors.New("booking not found")
+	ErrSeatsExceedHold    = errors.New("more seats than held")
+	ErrInvalidToken       = errors.New("invalid confirm token")
+	ErrSeatsBelowTaken    = errors.New("total seats below confirmed seats")
+	ErrNotOrganizer       = errors.New("not the event organizer")
+	ErrNotPending         = errors.New("booking is not pending")
+	ErrInvalidSeatType    = errors.New("invalid seat type")
+	ErrSeatTypeTotals     = errors.New("total seats don't match the seat type totals")
+	ErrNotConfirmed       = errors.New("booking is not confirmed")
+	ErrCheckedIn          = errors.New("booking already checked in")
+	ErrDuplicateBooking   = errors.New("user already has a booking for this event")
+	ErrBookingExpired     = errors.New("booking hold has expired")
+	ErrCancellationClosed = errors.New("cancellation window has closed")
+	ErrNotWaitlisted      = errors.New("user is not on the waitlist")
+	ErrAlreadyWaitlisted  = errors.New("user is already on the waitlist")
+	ErrSameEvent          = errors.New("booking is already for this event")
+	ErrBookingCancelled   = errors.New("booking is cancelled")
+	// The reservation is unknown, expired or already promoted, or the token is wrong
+	ErrReservationNotFound = errors.New("reservation not found")
+)
+
+// eventColumns lists the columns scanned by scanEvent, in order.
+const eventColumns = `id, name, date, total_seats, payment_time, COALESCE(grace_minutes, 0), organizer_id, COALESCE(timezone, ''), hide_exact_below, one_booking_per_user, cancellation_deadline_hours, series_id, created_at, metadata`
+
+// bookingColumns lists the columns scanned by scanBooking, in order.
+const bookingColumns = `id, event_id, user_name, seats, status, COALESCE(seat_type, ''), reference, created_at, confirmed_at, checked_in_at, COALESCE(cancel_reason, ''), version`
+
+// bookingHoldEnd is the SQL expression for the end of a booking's hold
+// before heartbeats. It expects bookings aliased as b and events as e. The
+// hold stored at booking time wins, so later edits to payment_time don't
+// shorten it; only bookings imported without one fall back to the event's
+// current settings.
+const bookingHoldEnd = `(COALESCE(b.expires_at, b.created_at + ((e.payment_time + COALESCE(e.grace_minutes, 0)) * interval '1 minute')) + b.extension_minutes * interval '1 minute')`
+
+// bookingExpiresAt is the SQL expression for the end of a booking's payment
+// window: its hold, pushed back by any heartbeat of a holder still paying.
+const bookingExpiresAt = `GREATEST(` + bookingHoldEnd + `, b.heartbeat_until)`
+
+// newBookingExpiresAt is the SQL expression for the stored hold of a booking
+// placed now on the event with id $1.
+const newBookingExpiresAt = `(SELECT CURRENT_TIMESTAMP + ((payment_time + COALESCE(grace_minutes, 0)) * interval '1 minute') FROM events WHERE id = $1)`
+
+// holdNotExpired is the SQL condition that a booking aliased as b is still
+// within its hold.
+const holdNotExpired = `EXISTS (SELECT 1 FROM events e WHERE e.id = b.event_id AND ` + bookingExpiresAt + ` >= NOW())`
+
+// reservedSeats is the SQL expression for the seats held by unexpired
+// reservations of the event aliased as e. They count against availability
+// like confirmed seats do.
+const reservedSeats = `(SELECT COALESCE(SUM(r.seats), 0) FROM reservations r WHERE r.event_id = e.id AND r.expires_at >= NOW())`
+
+// heldPendingSeats is the SQL expression for the seats of pending bookings
+// of the event aliased as e that are still within their hold. New bookings
+// can't take them, so two holds can't both be confirmed into the same seats.
+const heldPendingSeats = `(SELECT COALESCE(SUM(b.seats), 0) FROM bookings b WHERE b.event_id = e.id AND b.status = 'pending' AND ` + bookingExpiresAt + ` >= NOW())`
+
+// seatTypeTaken is the SQL expression for the seats of the seat type aliased
+// as st taken on the event aliased as e: confirmed ones and those of pending
+// bookings still within their hold, as heldPendingSeats counts them.
+const seatTypeTaken = `(SELECT COALESCE(SUM(b.seats), 0) FROM bookings b WHERE b.event_id = e.id AND b.seat_type = st.type AND (b.status = 'confirmed' OR (b.status = 'pending' AND ` + bookingExpiresAt + ` >= NOW())))`
+
+// scanEvent scans eventColumns into event, followed by any extra destinations
+// for columns selected after them.
+func scanEvent(row pgx.Row, event *models.Event, extra ...any) error {
+	dest := []any{
+		&event.ID,
+		&event.Name,
+		&event.Date,
+		&event.TotalSeats,
+		&event.PaymentTime,
+		&event.GraceMinutes,
+		&event.OrganizerID,
+		&event.Timezone,
+		&event.HideExactBelow,
+		&event.OneBookingPerUser,
+		&event.CancellationDeadlineHours,
+		&event.SeriesID,
+		&event.CreatedAt,
+		&event.Metadata,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	event.Localize()
+	return nil
+}
+
+// scanBooking scans bookingColumns into booking, followed by any extra
+// destinations for columns selected after them.
+func scanBooking(row pgx.Row, booking *models.Booking, extra ...any) error {
+	dest := []any{
+		&booking.ID,
+		&booking.EventID,
+		&booking.UserName,
+		&booking.Seats,
+		&booking.Status,
+		&booking.SeatType,
+		&booking.Reference,
+		&booking.CreatedAt,
+		&booking.ConfirmedAt,
+		&booking.CheckedInAt,
+		&booking.CancelReason,
+		&booking.Version,
+	}
+	return row.Scan(append(dest, extra...)...)
+}
+
 type Storage struct {
 	pool *pgxpool.Pool
+
+	rejectDuplicates bool
+	duplicateWindow  time.Duration
+	idempotentCreate bool
+	cleanupBatchSize int
+	expirySkew       time.Duration
+	readAttempts     int
+	readRetryBackoff time.Duration
+}
+
+type Option func(*Storage)
+
+// WithDuplicateEventGuard makes CreateEvent reject events whose name matches
+// an existing event scheduled within window of the new date.
+func WithDuplicateEventGuard(window time.Duration) Option {
+	return func(s *Storage) {
+		s.rejectDuplicates = true
+		s.duplicateWindow = window
+	}
+}
+
+// WithIdempotentCreate makes CreateEvent hand back the existing event, with
+// ErrEventExists, when the organizer already has one with the same name and
+// date. Without it such creations fail with ErrDuplicateEvent.
+func WithIdempotentCreate() Option {
+	return func(s *Storage) {
+		s.idempotentCreate = true
+	}
+}
+
+// WithCleanupBatchSize sets how many expired bookings CancelExpiredBookings
+// cancels per statement.
+func WithCleanupBatchSize(n int) Option {
+	return func(s *Storage) {
+		if n > 0 {
+			s.cleanupBatchSize = n
+		}
+	}
+}
+
+// WithExpirySkew makes CancelExpiredBookings leave bookings alone until
+// skew past their expiry, allowing for a created_at set by a clock running
+// ahead of the database's.
+func WithExpirySkew(skew time.Duration) Option {
+	return func(s *Storage) {
+		if skew > 0 {
+			s.expirySkew = skew
+		}
+	}
 }
 
-func New(pool *pgxpool.Pool) *Storage {
-	return &Storage{pool: pool}
+func New(pool *pgxpool.Pool, opts ...Option) *Storage {
+	s := &Storage{
+		pool:             pool,
+		cleanupBatchSize: 500,
+		readAttempts:     readAttempts,
+		readRetryBackoff: readRetryBackoff,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Storage) CreateEvent(ctx context.Context, event *models.Event) error {
@@ -26,42 +204,275 @@ func (s *Storage) CreateEvent(ctx context.Context, event *models.Event) error {
 	log.Printf("%s: Creating event - Name: %s, Date: %s, Total Seats: %d, Payment Time: %d min",
 		op, event.Name, event.Date.Format("2006-01-02 15:04:05"), event.TotalSeats, event.PaymentTime)
 
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.rejectDuplicateEvent(ctx, op, tx, event); err != nil {
+		return err
+	}
+
+	err = insertEvent(ctx, tx, event, nil)
+	if isUniqueViolation(err, eventIdentityConstraint) {
+		return s.existingEvent(ctx, op, tx, event)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to insert event: %v", op, err)
+		return fmt.Errorf("%s: %w", op, translateConstraint(err))
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit event transaction: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+
+	event.Localize()
+
+	log.Printf("%s: Successfully created event with ID: %d", op, event.ID)
+	return nil
+}
+
+// rejectDuplicateEvent fails with ErrDuplicateEvent when the duplicate guard
+// is on and an event of the same name lies within the window around event.
+func (s *Storage) rejectDuplicateEvent(ctx context.Context, op string, tx pgx.Tx, event *models.Event) error {
+	if !s.rejectDuplicates {
+		return nil
+	}
+
+	// Serialize creations of the same name so concurrent double-submits can't both pass the check
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, event.Name); err != nil {
+		log.Printf("%s: Failed to acquire duplicate check lock: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+
+	// Exact repeats are left to the unique constraint when creation is idempotent
+	var exists bool
+	err := tx.QueryRow(ctx, `SELECT EXISTS (
+            SELECT 1 FROM events WHERE name = $1 AND date BETWEEN $2 AND $3
+              AND NOT ($4 AND organizer_id IS NOT DISTINCT FROM $5 AND date = $6)
+        )`, event.Name, event.Date.Add(-s.duplicateWindow), event.Date.Add(s.duplicateWindow),
+		s.idempotentCreate, event.OrganizerID, event.Date).Scan(&exists)
+	if err != nil {
+		log.Printf("%s: Failed to check for duplicate event: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	if exists {
+		log.Printf("%s: Duplicate event rejected - Name: %s, Date: %s", op, event.Name, event.Date.Format("2006-01-02 15:04:05"))
+		return fmt.Errorf("%s: %w", op, ErrDuplicateEvent)
+	}
+	return nil
+}
+
+// insertEvent inserts event and its seat types within tx, filling in the ID
+// and created_at set by the database.
+func insertEvent(ctx context.Context, tx pgx.Tx, event *models.Event, seriesID *int) error {
 	// Return created_at as well so the caller has the timestamp that DB set
-	query := `INSERT INTO events (name, date, total_seats, payment_time) 
-			  VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	query := `INSERT INTO events (name, date, total_seats, payment_time, grace_minutes, organizer_id, timezone, hide_exact_below, 
+                                  one_booking_per_user, cancellation_deadline_hours, series_id, metadata) 
+			  VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11, $12) RETURNING id, created_at`
 
-	err := s.pool.QueryRow(ctx, query,
+	err := tx.QueryRow(ctx, query,
 		event.Name,
 		event.Date,
 		event.TotalSeats,
-		event.PaymentTime).Scan(&event.ID, &event.CreatedAt)
+		event.PaymentTime,
+		event.GraceMinutes,
+		event.OrganizerID,
+		event.Timezone,
+		event.HideExactBelow,
+		event.OneBookingPerUser,
+		event.CancellationDeadlineHours,
+		seriesID,
+		event.Metadata).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return err
+	}
+	event.SeriesID = seriesID
 
+	for _, st := range event.SeatTypes {
+		_, err = tx.Exec(ctx, `INSERT INTO seat_types (event_id, type, total, price) VALUES ($1, $2, $3, $4)`,
+			event.ID, st.Type, st.Total, st.Price)
+		if err != nil {
+			log.Printf("storage.insertEvent: Failed to insert seat type %q: %v", st.Type, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// existingEvent handles a create that hit the organizer/name/date constraint:
+// it loads the existing event into event when creation is idempotent and
+// reports a duplicate otherwise.
+func (s *Storage) existingEvent(ctx context.Context, op string, tx pgx.Tx, event *models.Event) error {
+	if !s.idempotentCreate {
+		log.Printf("%s: Duplicate event rejected - Name: %s, Date: %s", op, event.Name, event.Date.Format("2006-01-02 15:04:05"))
+		return fmt.Errorf("%s: %w", op, ErrDuplicateEvent)
+	}
+
+	// The failed insert aborted the transaction, so look the event up outside it
+	tx.Rollback(ctx)
+
+	var existing models.Event
+	err := s.retryRead(ctx, op, func() error {
+		return scanEvent(s.pool.QueryRow(ctx, `SELECT `+eventColumns+` FROM events 
+                                              WHERE organizer_id IS NOT DISTINCT FROM $1 AND name = $2 AND date = $3`,
+			event.OrganizerID, event.Name, event.Date), &existing)
+	})
 	if err != nil {
-		log.Printf("%s: Failed to insert event: %v", op, err)
+		log.Printf("%s: Failed to load existing event: %v", op, err)
 		return fmt.Errorf("%s: %v", op, err)
 	}
 
-	log.Printf("%s: Successfully created event with ID: %d", op, event.ID)
+	*event = existing
+	log.Printf("%s: Returning existing event with ID: %d", op, event.ID)
+	return fmt.Errorf("%s: %w", op, ErrEventExists)
+}
+
+// CreateEvents creates events in one transaction, so a failure of any of
+// them creates none. As with CreateEvent, an event identical to an existing
+// one is loaded in its place when creation is idempotent.
+func (s *Storage) CreateEvents(ctx context.Context, events []models.Event) error {
+	const op = "storage.CreateEvents"
+
+	log.Printf("%s: Creating %d events", op, len(events))
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.insertEvents(ctx, op, tx, events, nil); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit events transaction: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+
+	for i := range events {
+		events[i].Localize()
+	}
+
+	log.Printf("%s: Successfully created %d events", op, len(events))
+	return nil
+}
+
+// CreateEventSeries creates the instances of a recurring event in one
+// transaction, linked by a new series ID which it returns. A duplicate of
+// any instance rejects the whole series.
+func (s *Storage) CreateEventSeries(ctx context.Context, events []models.Event) (int, error) {
+	const op = "storage.CreateEventSeries"
+
+	log.Printf("%s: Creating series of %d events", op, len(events))
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return 0, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var seriesID int
+	if err := tx.QueryRow(ctx, `INSERT INTO event_series DEFAULT VALUES RETURNING id`).Scan(&seriesID); err != nil {
+		log.Printf("%s: Failed to insert series: %v", op, err)
+		return 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	if err := s.insertEvents(ctx, op, tx, events, &seriesID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit series transaction: %v", op, err)
+		return 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	for i := range events {
+		events[i].Localize()
+	}
+
+	log.Printf("%s: Successfully created series ID %d with %d events", op, seriesID, len(events))
+	return seriesID, nil
+}
+
+// insertEvents inserts events within tx, linked to seriesID when set. A
+// failure is a BatchEventError naming the event. Outside a series, an event
+// hitting the organizer/name/date constraint is loaded in place when creation
+// is idempotent; a series always consists of new events.
+func (s *Storage) insertEvents(ctx context.Context, op string, tx pgx.Tx, events []models.Event, seriesID *int) error {
+	for i := range events {
+		event := &events[i]
+		event.Date = event.Date.UTC()
+		if err := s.rejectDuplicateEvent(ctx, op, tx, event); err != nil {
+			return &BatchEventError{Index: i, Err: err}
+		}
+
+		// The savepoint keeps tx usable when the insert hits an existing event
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			log.Printf("%s: Failed to create savepoint: %v", op, err)
+			return fmt.Errorf("%s: %v", op, err)
+		}
+		err = insertEvent(ctx, sp, event, seriesID)
+		if isUniqueViolation(err, eventIdentityConstraint) {
+			sp.Rollback(ctx)
+			if seriesID != nil || !s.idempotentCreate {
+				log.Printf("%s: Duplicate event rejected - Name: %s, Date: %s", op, event.Name, event.Date.Format("2006-01-02 15:04:05"))
+				return &BatchEventError{Index: i, Err: fmt.Errorf("%s: %w", op, ErrDuplicateEvent)}
+			}
+			err = scanEvent(tx.QueryRow(ctx, `SELECT `+eventColumns+` FROM events 
+                                              WHERE organizer_id IS NOT DISTINCT FROM $1 AND name = $2 AND date = $3`,
+				event.OrganizerID, event.Name, event.Date), event)
+			if err != nil {
+				log.Printf("%s: Failed to load existing event: %v", op, err)
+				return fmt.Errorf("%s: %v", op, err)
+			}
+			log.Printf("%s: Using existing event with ID: %d", op, event.ID)
+			continue
+		}
+		if err != nil {
+			log.Printf("%s: Failed to insert event %d: %v", op, i, err)
+			return &BatchEventError{Index: i, Err: fmt.Errorf("%s: %w", op, translateConstraint(err))}
+		}
+		if err := sp.Commit(ctx); err != nil {
+			log.Printf("%s: Failed to release savepoint: %v", op, err)
+			return fmt.Errorf("%s: %v", op, err)
+		}
+	}
 	return nil
 }
 
+func (s *Storage) SeriesExists(ctx context.Context, seriesID int) (bool, error) {
+	const op = "storage.SeriesExists"
+
+	var exists bool
+	err := s.retryRead(ctx, op, func() error {
+		return s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM event_series WHERE id = $1)`, seriesID).Scan(&exists)
+	})
+	if err != nil {
+		log.Printf("%s: Failed to check series ID %d: %v", op, seriesID, err)
+		return false, fmt.Errorf("%s: %v", op, err)
+	}
+	return exists, nil
+}
+
 func (s *Storage) GetEvent(ctx context.Context, id int) (*models.Event, error) {
 	const op = "storage.GetEvent"
 
 	log.Printf("%s: Retrieving event with ID: %d", op, id)
 
-	query := `SELECT id, name, date, total_seats, payment_time, created_at 
-              FROM events WHERE id = $1`
+	query := `SELECT ` + eventColumns + ` FROM events WHERE id = $1`
 
 	var event models.Event
-	err := s.pool.QueryRow(ctx, query, id).Scan(
-		&event.ID,
-		&event.Name,
-		&event.Date,
-		&event.TotalSeats,
-		&event.PaymentTime,
-		&event.CreatedAt,
-	)
+	err := s.retryRead(ctx, op, func() error {
+		return scanEvent(s.pool.QueryRow(ctx, query, id), &event)
+	})
 	if err != nil {
 		log.Printf("%s: Failed to retrieve event ID %d: %v", op, id, err)
 		return nil, fmt.Errorf("%s: %v", op, err)
@@ -71,192 +482,2238 @@ func (s *Storage) GetEvent(ctx context.Context, id int) (*models.Event, error) {
 	return &event, nil
 }
 
-func (s *Storage) BookSeats(ctx context.Context, booking *models.Booking) error {
-	const op = "storage.BookSeats"
+func (s *Storage) PatchEvent(ctx context.Context, id int, patch models.EventPatch) (*models.Event, error) {
+	const op = "storage.PatchEvent"
 
-	log.Printf("%s: Starting seat booking - User: %s, Seats: %d, Event ID: %d",
-		op, booking.UserName, booking.Seats, booking.EventID)
+	log.Printf("%s: Patching event ID: %d", op, id)
 
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.begin(ctx)
 	if err != nil {
 		log.Printf("%s: Failed to begin transaction: %v", op, err)
-		return fmt.Errorf("%s: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
 	}
 	defer tx.Rollback(ctx)
 
-	var available int
+	// Lock the event so a concurrent confirmation can't slip under the new capacity
+	var confirmed int
+	var typeTotals *int
 	err = tx.QueryRow(ctx, `
-        SELECT total_seats - COALESCE(SUM(seats), 0) 
-        FROM events LEFT JOIN bookings 
-        ON events.id = bookings.event_id 
-        AND bookings.status = 'confirmed'
-        WHERE events.id = $1
-        GROUP BY events.id`, booking.EventID).Scan(&available)
-
+        SELECT COALESCE((
+            SELECT SUM(b.seats) FROM bookings b
+            WHERE b.event_id = e.id AND b.status = 'confirmed'
+        ), 0),
+        (SELECT SUM(st.total) FROM seat_types st WHERE st.event_id = e.id)
+        FROM events e
+        WHERE e.id = $1
+        FOR UPDATE`, id).Scan(&confirmed, &typeTotals)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Event %d not found", op, id)
+		return nil, fmt.Errorf("%s: %w", op, ErrEventNotFound)
+	}
 	if err != nil {
-		log.Printf("%s: Failed to check available seats for event %d: %v", op, booking.EventID, err)
-		return fmt.Errorf("%s: %v", op, err)
+		log.Printf("%s: Failed to lock event %d: %v", op, id, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
 	}
 
-	log.Printf("%s: Available seats for event %d: %d, requested: %d",
-		op, booking.EventID, available, booking.Seats)
-
-	if available < booking.Seats {
-		log.Printf("%s: Not enough seats - Available: %d, Requested: %d, User: %s, Event: %d",
-			op, available, booking.Seats, booking.UserName, booking.EventID)
-		return fmt.Errorf("%s: not enough seats", op)
+	if patch.TotalSeats != nil && *patch.TotalSeats < confirmed {
+		log.Printf("%s: Refusing to shrink event %d to %d seats, %d confirmed", op, id, *patch.TotalSeats, confirmed)
+		return nil, fmt.Errorf("%s: %w", op, ErrSeatsBelowTaken)
+	}
+	// Seat types split the whole event, so their totals must keep adding up
+	if patch.TotalSeats != nil && typeTotals != nil && *patch.TotalSeats != *typeTotals {
+		log.Printf("%s: Refusing to set event %d to %d seats, seat types total %d", op, id, *patch.TotalSeats, *typeTotals)
+		return nil, fmt.Errorf("%s: %w", op, ErrSeatTypeTotals)
 	}
 
-	// Return id, status and created_at so booking struct reflects DB defaults
-	query := `INSERT INTO bookings (event_id, user_name, seats) 
-			  VALUES ($1, $2, $3) RETURNING id, status, created_at`
-
-	err = tx.QueryRow(ctx, query,
-		booking.EventID,
-		booking.UserName,
-		booking.Seats).Scan(&booking.ID, &booking.Status, &booking.CreatedAt)
+	var sets []string
+	var args []any
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if patch.Name != nil {
+		set("name", *patch.Name)
+	}
+	if patch.Date != nil {
+		set("date", patch.Date.UTC())
+	}
+	if patch.TotalSeats != nil {
+		set("total_seats", *patch.TotalSeats)
+	}
+	if patch.PaymentTime != nil {
+		set("payment_time", *patch.PaymentTime)
+	}
+	if patch.GraceMinutes != nil {
+		set("grace_minutes", *patch.GraceMinutes)
+	}
+	if patch.HideExactBelow != nil {
+		set("hide_exact_below", *patch.HideExactBelow)
+	}
+	if patch.CancellationDeadlineHours != nil {
+		set("cancellation_deadline_hours", *patch.CancellationDeadlineHours)
+	}
 
+	var event models.Event
+	if len(sets) == 0 {
+		err = scanEvent(tx.QueryRow(ctx, `SELECT `+eventColumns+` FROM events WHERE id = $1`, id), &event)
+	} else {
+		args = append(args, id)
+		query := fmt.Sprintf(`UPDATE events SET %s WHERE id = $%d RETURNING `+eventColumns,
+			strings.Join(sets, ", "), len(args))
+		err = scanEvent(tx.QueryRow(ctx, query, args...), &event)
+	}
 	if err != nil {
-		log.Printf("%s: Failed to insert booking: %v", op, err)
-		return fmt.Errorf("%s: %v", op, err)
+		log.Printf("%s: Failed to update event %d: %v", op, id, err)
+		return nil, fmt.Errorf("%s: %w", op, translateConstraint(err))
 	}
 
 	if err := tx.Commit(ctx); err != nil {
-		log.Printf("%s: Failed to commit booking transaction: %v", op, err)
-		return fmt.Errorf("%s: %v", op, err)
+		log.Printf("%s: Failed to commit event patch: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
 	}
 
-	log.Printf("%s: Successfully created booking ID: %d for user: %s, seats: %d, event: %d",
-		op, booking.ID, booking.UserName, booking.Seats, booking.EventID)
-	return nil
+	log.Printf("%s: Successfully patched %d fields of event ID: %d", op, len(sets), id)
+	return &event, nil
 }
 
-func (s *Storage) ConfirmBooking(ctx context.Context, eventID int, userName string) error {
-	const op = "storage.ConfirmBooking"
+// Event statuses accepted by DeleteEventsBefore. Events have no stored
+// status; completed means the event date has passed.
+const (
+	EventStatusCompleted = "completed"
+	EventStatusAny       = "any"
+)
 
-	log.Printf("%s: Confirming booking for user: %s, event ID: %d", op, userName, eventID)
+// RescheduleEvent moves an event to a new date and returns the bookings that
+// were not cancelled. With reconfirm set, confirmed bookings go back to
+// pending with a fresh payment window counted from now.
+func (s *Storage) RescheduleEvent(ctx context.Context, id int, date time.Time, reconfirm bool) (*models.Event, []models.Booking, error) {
+	const op = "storage.RescheduleEvent"
 
-	query := `UPDATE bookings SET status = 'confirmed' 
-              WHERE event_id = $1 AND user_name = $2 AND status = 'pending'`
+	log.Printf("%s: Rescheduling event ID: %d to %s, reconfirm: %t", op, id, date.UTC().Format("2006-01-02 15:04:05"), reconfirm)
 
-	res, err := s.pool.Exec(ctx, query, eventID, userName)
+	tx, err := s.begin(ctx)
 	if err != nil {
-		log.Printf("%s: Failed to update booking status: %v", op, err)
-		return fmt.Errorf("%s: %v", op, err)
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
 	}
+	defer tx.Rollback(ctx)
 
-	rowsAffected := res.RowsAffected()
-	if rowsAffected == 0 {
-		log.Printf("%s: No pending booking found for user: %s, event ID: %d", op, userName, eventID)
-		return fmt.Errorf("%s: booking not found", op)
+	var event models.Event
+	err = scanEvent(tx.QueryRow(ctx, `UPDATE events SET date = $1 WHERE id = $2 RETURNING `+eventColumns, date.UTC(), id), &event)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Event %d not found", op, id)
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrEventNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to update event %d: %v", op, id, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
 	}
 
-	log.Printf("%s: Successfully confirmed booking for user: %s, event ID: %d", op, userName, eventID)
-	return nil
-}
-
-func (s *Storage) GetEventBookings(ctx context.Context, eventID int) ([]models.Booking, error) {
-	const op = "storage.GetEventBookings"
-
-	log.Printf("%s: Retrieving bookings for event ID: %d", op, eventID)
-
-	query := `SELECT id, event_id, user_name, seats, status, created_at 
-              FROM bookings WHERE event_id = $1`
+	if reconfirm {
+		// Extend rather than touch created_at so booking order is preserved
+		_, err = tx.Exec(ctx, `UPDATE bookings 
+                               SET status = 'pending', 
+                                   confirmed_at = NULL,
+                                   version = version + 1,
+                                   extension_minutes = CEIL(EXTRACT(EPOCH FROM (NOW() - created_at)) / 60)
+                               WHERE event_id = $1 AND status = 'confirmed'`, id)
+		if err != nil {
+			log.Printf("%s: Failed to reset confirmations for event %d: %v", op, id, err)
+			return nil, nil, fmt.Errorf("%s: %v", op, err)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE events SET confirmed_seats = 0 WHERE id = $1`, id); err != nil {
+			log.Printf("%s: Failed to reset confirmed seats for event %d: %v", op, id, err)
+			return nil, nil, fmt.Errorf("%s: %v", op, err)
+		}
+	}
 
-	rows, err := s.pool.Query(ctx, query, eventID)
+	rows, err := tx.Query(ctx, `SELECT `+bookingColumns+` 
+                                FROM bookings WHERE event_id = $1 AND status != 'cancelled'
+                                ORDER BY created_at ASC, id ASC`, id)
 	if err != nil {
-		log.Printf("%s: Failed to query bookings for event %d: %v", op, eventID, err)
-		return nil, fmt.Errorf("%s: %v", op, err)
+		log.Printf("%s: Failed to query bookings for event %d: %v", op, id, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
 	}
 	defer rows.Close()
 
-	var bookings []models.Booking
+	bookings := []models.Booking{}
 	for rows.Next() {
 		var b models.Booking
-		err := rows.Scan(&b.ID, &b.EventID, &b.UserName, &b.Seats, &b.Status, &b.CreatedAt)
+		err := scanBooking(rows, &b)
 		if err != nil {
 			log.Printf("%s: Failed to scan booking row: %v", op, err)
-			return nil, fmt.Errorf("%s: %v", op, err)
+			return nil, nil, fmt.Errorf("%s: %v", op, err)
 		}
 		bookings = append(bookings, b)
 	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate booking rows: %v", op, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
 
-	log.Printf("%s: Retrieved %d bookings for event ID: %d", op, len(bookings), eventID)
-	return bookings, nil
-}
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit reschedule: %v", op, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
 
-func (s *Storage) CancelExpiredBookings(ctx context.Context) error {
-    const op = "storage.CancelExpiredBookings"
+	log.Printf("%s: Rescheduled event %d, %d bookings affected", op, id, len(bookings))
+	return &event, bookings, nil
+}
 
-    log.Printf("%s: Starting expired bookings cleanup", op)
+func (s *Storage) DeleteEventsBefore(ctx context.Context, before time.Time, status string) (int64, error) {
+	const op = "storage.DeleteEventsBefore"
 
-    // Более простой и надежный запрос
-    query := `UPDATE bookings 
-              SET status = 'cancelled'
-              FROM events
-              WHERE bookings.event_id = events.id
-              AND bookings.status = 'pending'
-              AND bookings.created_at < (NOW() - (events.payment_time * INTERVAL '1 minute'))`
+	log.Printf("%s: Deleting %s events before %s", op, status, before.UTC().Format("2006-01-02 15:04:05"))
 
-    res, err := s.pool.Exec(ctx, query)
-    if err != nil {
-        log.Printf("%s: Failed to cancel expired bookings: %v", op, err)
-        return fmt.Errorf("%s: %v", op, err)
-    }
+	conds := []string{"date < $1"}
+	switch status {
+	case EventStatusCompleted:
+		conds = append(conds, "date < (NOW() AT TIME ZONE 'UTC')")
+	case EventStatusAny:
+	default:
+		return 0, fmt.Errorf("%s: unknown event status %q", op, status)
+	}
 
-    cancelledCount := res.RowsAffected()
-    log.Printf("%s: Cancelled %d expired bookings", op, cancelledCount)
-    return nil
-}
-func (s *Storage) GetAvailableSeats(ctx context.Context, eventID int) (int, error) {
-	const op = "storage.GetAvailableSeats"
+	// Each batch commits on its own so a large purge doesn't hold locks for long;
+	// bookings and their dependents go with the event through ON DELETE CASCADE
+	query := `DELETE FROM events WHERE id IN (
+                  SELECT id FROM events` + whereClause(conds) + `
+                  ORDER BY id
+                  LIMIT $2
+              )`
 
-	log.Printf("%s: Calculating available seats for event ID: %d", op, eventID)
+	var deleted int64
+	for batch := 1; ; batch++ {
+		if err := ctx.Err(); err != nil {
+			log.Printf("%s: Deletion interrupted before batch %d after %d events: %v", op, batch, deleted, err)
+			return deleted, fmt.Errorf("%s: %w", op, err)
+		}
 
-	query := `
-        SELECT e.total_seats - COALESCE(SUM(b.seats), 0) 
-        FROM events e
-        LEFT JOIN bookings b ON e.id = b.event_id AND b.status = 'confirmed'
-        WHERE e.id = $1
-        GROUP BY e.id, e.total_seats
-    `
+		res, err := s.pool.Exec(ctx, query, before.UTC(), s.cleanupBatchSize)
+		if err != nil {
+			log.Printf("%s: Failed to delete events in batch %d: %v", op, batch, err)
+			return deleted, fmt.Errorf("%s: %v", op, err)
+		}
+		deleted += res.RowsAffected()
 
-	var available int
-	err := s.pool.QueryRow(ctx, query, eventID).Scan(&available)
-	if err != nil {
-		log.Printf("%s: Failed to calculate available seats for event %d: %v", op, eventID, err)
-		return 0, fmt.Errorf("%s: %v", op, err)
+		if res.RowsAffected() < int64(s.cleanupBatchSize) {
+			break
+		}
 	}
 
-	log.Printf("%s: Event ID %d has %d available seats", op, eventID, available)
-	return available, nil
+	log.Printf("%s: Deleted %d events", op, deleted)
+	return deleted, nil
 }
 
-func (s *Storage) GetAllEvents(ctx context.Context) ([]models.Event, error) {
-	const op = "storage.GetAllEvents"
+// RecomputeConfirmedSeats recalculates an event's confirmed_seats counter
+// from its confirmed bookings, repairing any drift.
+func (s *Storage) RecomputeConfirmedSeats(ctx context.Context, eventID int) (models.SeatRecount, error) {
+	const op = "storage.RecomputeConfirmedSeats"
 
-	log.Printf("%s: Retrieving all events", op)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return models.SeatRecount{}, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
 
-	query := `SELECT id, name, date, total_seats, payment_time, created_at FROM events ORDER BY date ASC`
+	// Every change to confirmed bookings also moves the counter under the event
+	// row lock, so holding it keeps the bookings still while they are summed
+	recount := models.SeatRecount{EventID: eventID}
+	err = tx.QueryRow(ctx, `SELECT confirmed_seats FROM events WHERE id = $1 FOR UPDATE`, eventID).Scan(&recount.Before)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Event %d not found", op, eventID)
+		return models.SeatRecount{}, fmt.Errorf("%s: %w", op, ErrEventNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to lock event %d: %v", op, eventID, err)
+		return models.SeatRecount{}, fmt.Errorf("%s: %v", op, err)
+	}
 
-	rows, err := s.pool.Query(ctx, query)
+	err = tx.QueryRow(ctx, `UPDATE events SET confirmed_seats = COALESCE((
+                SELECT SUM(seats) FROM bookings WHERE event_id = $1 AND status = 'confirmed'
+            ), 0) WHERE id = $1 RETURNING confirmed_seats`, eventID).Scan(&recount.After)
 	if err != nil {
-		log.Printf("%s: Failed to query all events: %v", op, err)
-		return nil, fmt.Errorf("%s: %v", op, err)
+		log.Printf("%s: Failed to recount confirmed seats of event %d: %v", op, eventID, err)
+		return models.SeatRecount{}, fmt.Errorf("%s: %v", op, err)
 	}
-	defer rows.Close()
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit recount: %v", op, err)
+		return models.SeatRecount{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Recomputed confirmed seats of event %d: before %d, after %d", op, eventID, recount.Before, recount.After)
+	return recount, nil
+}
+
+// RecomputeAllConfirmedSeats recalculates the confirmed_seats counter of
+// every event and returns the events whose counter had drifted.
+func (s *Storage) RecomputeAllConfirmedSeats(ctx context.Context) ([]models.SeatRecount, error) {
+	const op = "storage.RecomputeAllConfirmedSeats"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Locked in id order, as RecomputeConfirmedSeats would, before anything is summed
+	if _, err := tx.Exec(ctx, `SELECT id FROM events ORDER BY id FOR UPDATE`); err != nil {
+		log.Printf("%s: Failed to lock events: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	rows, err := tx.Query(ctx, `
+        UPDATE events e SET confirmed_seats = c.seats
+        FROM (
+            SELECT e2.id, e2.confirmed_seats AS before, COALESCE(SUM(b.seats), 0) AS seats
+            FROM events e2 LEFT JOIN bookings b ON b.event_id = e2.id AND b.status = 'confirmed'
+            GROUP BY e2.id
+        ) c
+        WHERE e.id = c.id AND e.confirmed_seats <> c.seats
+        RETURNING e.id, c.before, e.confirmed_seats`)
+	if err != nil {
+		log.Printf("%s: Failed to recount confirmed seats: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	var recounts []models.SeatRecount
+	for rows.Next() {
+		var r models.SeatRecount
+		if err := rows.Scan(&r.EventID, &r.Before, &r.After); err != nil {
+			log.Printf("%s: Failed to scan recount: %v", op, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+		log.Printf("%s: Repaired confirmed seats of event %d: before %d, after %d", op, r.EventID, r.Before, r.After)
+		recounts = append(recounts, r)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Error iterating recounts: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit recount: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Repaired %d events", op, len(recounts))
+	return recounts, nil
+}
+
+// VerifyIntegrity looks for events confirmed beyond their capacity and for
+// active bookings whose event is missing, without repairing anything. The
+// count and the violations are read in one snapshot.
+func (s *Storage) VerifyIntegrity(ctx context.Context) (models.IntegrityReport, error) {
+	const op = "storage.VerifyIntegrity"
+
+	var tx pgx.Tx
+	err := s.retryRead(ctx, op, func() error {
+		var err error
+		tx, err = s.beginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+		return err
+	})
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return models.IntegrityReport{}, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	report := models.IntegrityReport{Violations: []models.IntegrityViolation{}}
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM events`).Scan(&report.EventsChecked); err != nil {
+		log.Printf("%s: Failed to count events: %v", op, err)
+		return models.IntegrityReport{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	rows, err := tx.Query(ctx, `
+        SELECT $1::text, id, NULL::int,
+               format('confirmed_seats %s exceeds total_seats %s', confirmed_seats, total_seats)
+        FROM events WHERE confirmed_seats > total_seats
+        UNION ALL
+        SELECT $2::text, b.event_id, b.id,
+               format('%s booking references missing event', b.status)
+        FROM bookings b LEFT JOIN events e ON e.id = b.event_id
+        WHERE b.status IN ('pending', 'confirmed') AND e.id IS NULL
+        ORDER BY 1, 2, 3`, models.CheckOverconfirmedEvent, models.CheckOrphanedBooking)
+	if err != nil {
+		log.Printf("%s: Failed to query violations: %v", op, err)
+		return models.IntegrityReport{}, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v models.IntegrityViolation
+		if err := rows.Scan(&v.Check, &v.EventID, &v.BookingID, &v.Detail); err != nil {
+			log.Printf("%s: Failed to scan violation: %v", op, err)
+			return models.IntegrityReport{}, fmt.Errorf("%s: %v", op, err)
+		}
+		log.Printf("%s: Violation %s: %s", op, v.Check, v.Detail)
+		report.Violations = append(report.Violations, v)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Error iterating violations: %v", op, err)
+		return models.IntegrityReport{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Checked %d events, found %d violations", op, report.EventsChecked, len(report.Violations))
+	return report, nil
+}
+
+func (s *Storage) BookSeats(ctx context.Context, booking *models.Booking) error {
+	_, err := s.BookSeatsWithAvailability(ctx, booking)
+	return err
+}
+
+// BookSeatsWithAvailability is BookSeats that also reports the event's
+// availability as seen by the booking transaction, with the new booking's
+// seats taken.
+func (s *Storage) BookSeatsWithAvailability(ctx context.Context, booking *models.Booking) (models.SeatAvailability, error) {
+	return s.bookSeats(ctx, booking, nil)
+}
+
+// bookSeats books like BookSeatsWithAvailability. A non-nil claim runs first
+// in the booking transaction, releasing whatever held the seats before, so
+// they are free again for the availability check.
+func (s *Storage) bookSeats(ctx context.Context, booking *models.Booking, claim func(pgx.Tx) error) (models.SeatAvailability, error) {
+	const op = "storage.BookSeats"
+
+	log.Printf("%s: Starting seat booking - User: %s, Seats: %d, Event ID: %d",
+		op, booking.UserName, booking.Seats, booking.EventID)
+
+	phases := startPhases(ctx)
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return models.SeatAvailability{}, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Pending bookings hold seats, so concurrent bookings of an event have to
+	// check capacity one at a time
+	var left models.SeatAvailability
+	var onePerUser bool
+	err = tx.QueryRow(ctx, `SELECT hide_exact_below, one_booking_per_user FROM events WHERE id = $1 FOR UPDATE`,
+		booking.EventID).Scan(&left.HideExactBelow, &onePerUser)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Event %d not found", op, booking.EventID)
+		return models.SeatAvailability{}, fmt.Errorf("%s: %w", op, ErrEventNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to lock event %d: %v", op, booking.EventID, err)
+		return models.SeatAvailability{}, fmt.Errorf("%s: %v", op, err)
+	}
+	phases.done(PhaseAcquire)
+
+	if claim != nil {
+		if err := claim(tx); err != nil {
+			return models.SeatAvailability{}, err
+		}
+	}
+
+	err = tx.QueryRow(ctx, `
+        SELECT e.total_seats::bigint - COALESCE((
+            SELECT SUM(c.seats) FROM bookings c WHERE c.event_id = e.id AND c.status = 'confirmed'
+        ), 0) - `+heldPendingSeats+` - `+reservedSeats+`
+        FROM events e WHERE e.id = $1`, booking.EventID).Scan(&left.Available)
+	if err != nil {
+		log.Printf("%s: Failed to check available seats for event %d: %v", op, booking.EventID, err)
+		return models.SeatAvailability{}, fmt.Errorf("%s: %v", op, err)
+	}
+	available := left.Available
+
+	// The unique index settles races; this check just spares a doomed insert
+	if onePerUser {
+		var exists bool
+		err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM bookings 
+                                WHERE event_id = $1 AND user_name = $2 AND status <> 'cancelled')`,
+			booking.EventID, booking.UserName).Scan(&exists)
+		if err != nil {
+			log.Printf("%s: Failed to check existing bookings of user %s: %v", op, booking.UserName, err)
+			return models.SeatAvailability{}, fmt.Errorf("%s: %v", op, err)
+		}
+		if exists {
+			log.Printf("%s: User %s already has a booking for event %d", op, booking.UserName, booking.EventID)
+			return models.SeatAvailability{}, fmt.Errorf("%s: %w", op, ErrDuplicateBooking)
+		}
+	}
+
+	// Events with seat types are booked per type, against that type's own capacity
+	var typeAvailable *int64
+	var hasTypes bool
+	err = tx.QueryRow(ctx, `
+        SELECT EXISTS (SELECT 1 FROM seat_types WHERE event_id = $1),
+               (SELECT st.total::bigint - `+seatTypeTaken+` 
+                FROM seat_types st JOIN events e ON e.id = st.event_id
+                WHERE st.event_id = $1 AND st.type = $2)`,
+		booking.EventID, booking.SeatType).Scan(&hasTypes, &typeAvailable)
+	if err != nil {
+		log.Printf("%s: Failed to check seat type for event %d: %v", op, booking.EventID, err)
+		return models.SeatAvailability{}, fmt.Errorf("%s: %v", op, err)
+	}
+	switch {
+	case hasTypes && typeAvailable == nil, !hasTypes && booking.SeatType != "":
+		log.Printf("%s: Invalid seat type %q for event %d", op, booking.SeatType, booking.EventID)
+		return models.SeatAvailability{}, fmt.Errorf("%s: %w", op, ErrInvalidSeatType)
+	case hasTypes:
+		available = min(available, *typeAvailable)
+	}
+
+	// Without min_seats the whole request must fit; with it, as many seats as
+	// are free up to Seats are booked, provided that is at least MinSeats
+	required := booking.Seats
+	if booking.MinSeats > 0 {
+		required = booking.MinSeats
+	}
+
+	log.Printf("%s: Available seats for event %d: %d, requested: %d, required: %d",
+		op, booking.EventID, available, booking.Seats, required)
+
+	if available < int64(required) {
+		log.Printf("%s: Not enough seats - Available: %d, Required: %d, User: %s, Event: %d",
+			op, available, required, booking.UserName, booking.EventID)
+		return models.SeatAvailability{}, fmt.Errorf("%s: %w", op, &ShortfallError{Requested: int64(required), Available: available, HideExactBelow: left.HideExactBelow})
+	}
+	if available < int64(booking.Seats) {
+		log.Printf("%s: Booking %d of %d requested seats for user: %s", op, available, booking.Seats, booking.UserName)
+		booking.Seats = int(available)
+	}
+	phases.done(PhaseCheckAvailability)
+
+	token, tokenHash, err := newConfirmToken()
+	if err != nil {
+		log.Printf("%s: Failed to generate confirm token: %v", op, err)
+		return models.SeatAvailability{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	// Return id, status and created_at so booking struct reflects DB defaults
+	query := `INSERT INTO bookings (event_id, user_name, seats, confirm_token_hash, seat_type, one_per_user, expires_at) 
+			  VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, ` + newBookingExpiresAt + `) RETURNING id, status, reference, created_at, version`
+
+	err = tx.QueryRow(ctx, query,
+		booking.EventID,
+		booking.UserName,
+		booking.Seats,
+		tokenHash,
+		booking.SeatType,
+		onePerUser).Scan(&booking.ID, &booking.Status, &booking.Reference, &booking.CreatedAt, &booking.Version)
+
+	if isUniqueViolation(err, onePerUserConstraint) {
+		log.Printf("%s: User %s already has a booking for event %d", op, booking.UserName, booking.EventID)
+		return models.SeatAvailability{}, fmt.Errorf("%s: %w", op, ErrDuplicateBooking)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to insert booking: %v", op, err)
+		return models.SeatAvailability{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit booking transaction: %v", op, err)
+		return models.SeatAvailability{}, fmt.Errorf("%s: %v", op, err)
+	}
+	phases.done(PhaseInsert)
+	booking.ConfirmToken = token
+
+	log.Printf("%s: Successfully created booking ID: %d for user: %s, seats: %d, event: %d",
+		op, booking.ID, booking.UserName, booking.Seats, booking.EventID)
+	left.Available = clampAvailable(op, booking.EventID, left.Available-int64(booking.Seats))
+	return left, nil
+}
+
+// BookSeatsGroup books seats for every member in one transaction. With
+// requireAll the whole group is refused unless every member fits; otherwise
+// members are booked in order while seats remain and the rest are left out.
+func (s *Storage) BookSeatsGroup(ctx context.Context, eventID int, members []models.GroupMember, requireAll bool) ([]models.Booking, error) {
+	const op = "storage.BookSeatsGroup"
+
+	// Summed as int64 so a large group can't wrap around on 32-bit platforms
+	var requested int64
+	for _, m := range members {
+		requested += int64(m.Seats)
+	}
+
+	log.Printf("%s: Starting group booking - Members: %d, Seats: %d, Event ID: %d",
+		op, len(members), requested, eventID)
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the event row so concurrent group bookings check capacity one at a time
+	var available int64
+	var hasTypes, onePerUser bool
+	var hideExactBelow int
+	err = tx.QueryRow(ctx, `
+        SELECT e.total_seats::bigint - COALESCE((
+            SELECT SUM(b.seats) FROM bookings b
+            WHERE b.event_id = e.id AND b.status = 'confirmed'
+        ), 0) - `+heldPendingSeats+` - `+reservedSeats+`,
+        EXISTS (SELECT 1 FROM seat_types st WHERE st.event_id = e.id),
+        e.one_booking_per_user, e.hide_exact_below
+        FROM events e
+        WHERE e.id = $1
+        FOR UPDATE`, eventID).Scan(&available, &hasTypes, &onePerUser, &hideExactBelow)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Event %d not found", op, eventID)
+		return nil, fmt.Errorf("%s: %w", op, ErrEventNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to check available seats for event %d: %v", op, eventID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	// Group members carry no seat type, so tiered events must be booked one by one
+	if hasTypes {
+		log.Printf("%s: Event %d has seat types, group booking refused", op, eventID)
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidSeatType)
+	}
+
+	log.Printf("%s: Available seats for event %d: %d, requested: %d", op, eventID, available, requested)
+
+	if requireAll && available < requested {
+		log.Printf("%s: Not enough seats for group - Available: %d, Requested: %d, Event: %d",
+			op, available, requested, eventID)
+		return nil, fmt.Errorf("%s: %w", op, &ShortfallError{Requested: requested, Available: available, HideExactBelow: hideExactBelow})
+	}
+
+	query := `INSERT INTO bookings (event_id, user_name, seats, confirm_token_hash, one_per_user, expires_at) 
+			  VALUES ($1, $2, $3, $4, $5, ` + newBookingExpiresAt + `) RETURNING id, status, reference, created_at, version`
+
+	// Every member gets their own token so they confirm independently
+	bookings := make([]models.Booking, 0, len(members))
+	remaining := available
+	for _, m := range members {
+		if int64(m.Seats) > remaining {
+			log.Printf("%s: Leaving out member %s, %d seats requested, %d remaining", op, m.UserName, m.Seats, remaining)
+			continue
+		}
+		remaining -= int64(m.Seats)
+
+		token, tokenHash, err := newConfirmToken()
+		if err != nil {
+			log.Printf("%s: Failed to generate confirm token: %v", op, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+
+		b := models.Booking{
+			EventID:      eventID,
+			UserName:     m.UserName,
+			Seats:        m.Seats,
+			ConfirmToken: token,
+		}
+		err = tx.QueryRow(ctx, query, b.EventID, b.UserName, b.Seats, tokenHash, onePerUser).Scan(&b.ID, &b.Status, &b.Reference, &b.CreatedAt, &b.Version)
+		if isUniqueViolation(err, onePerUserConstraint) {
+			log.Printf("%s: User %s already has a booking for event %d", op, m.UserName, eventID)
+			return nil, fmt.Errorf("%s: %s: %w", op, m.UserName, ErrDuplicateBooking)
+		}
+		if err != nil {
+			log.Printf("%s: Failed to insert booking for user %s: %v", op, m.UserName, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+		bookings = append(bookings, b)
+	}
+	if len(bookings) == 0 {
+		log.Printf("%s: No group member fits - Available: %d, Event: %d", op, available, eventID)
+		return nil, fmt.Errorf("%s: %w", op, &ShortfallError{Requested: requested, Available: available, HideExactBelow: hideExactBelow})
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit group booking transaction: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Successfully created %d bookings for event %d", op, len(bookings), eventID)
+	return bookings, nil
+}
+
+func (s *Storage) ConfirmBooking(ctx context.Context, eventID int, userName, token string) error {
+	_, err := s.ConfirmBookingWithID(ctx, eventID, userName, token)
+	return err
+}
+
+// ConfirmBookingWithID is ConfirmBooking that also reports the ID of the
+// confirmed booking.
+func (s *Storage) ConfirmBookingWithID(ctx context.Context, eventID int, userName, token string) (int, error) {
+	const op = "storage.ConfirmBooking"
+
+	log.Printf("%s: Confirming booking for user: %s, event ID: %d", op, userName, eventID)
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return 0, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var bookingID, seats, version int
+	var seatType string
+	err = tx.QueryRow(ctx, `SELECT id, seats, version, COALESCE(seat_type, '') FROM bookings b
+                            WHERE event_id = $1 AND user_name = $2 AND status = 'pending' AND confirm_token_hash = $3
+                              AND `+holdNotExpired,
+		eventID, userName, hashConfirmToken(token)).Scan(&bookingID, &seats, &version, &seatType)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("%s: %w", op, s.confirmFailure(ctx, op, eventID, userName, token))
+	}
+	if err != nil {
+		log.Printf("%s: Failed to load pending booking: %v", op, err)
+		return 0, fmt.Errorf("%s: %v", op, err)
+	}
+	if err := checkBookingVersion(ctx, op, bookingID, version); err != nil {
+		return 0, err
+	}
+
+	// The guarded increment is what keeps racing confirmations within capacity;
+	// it also takes the event row lock before the booking row, as ConfirmPartial does
+	res, err := tx.Exec(ctx, `UPDATE events e SET confirmed_seats = e.confirmed_seats + $1 
+                              WHERE e.id = $2 AND e.confirmed_seats::bigint + $1 + `+reservedSeats+` <= e.total_seats`, seats, eventID)
+	if err != nil {
+		log.Printf("%s: Failed to reserve confirmed seats for event %d: %v", op, eventID, err)
+		return 0, fmt.Errorf("%s: %v", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		log.Printf("%s: Not enough seats to confirm booking %d (%d seats), event: %d", op, bookingID, seats, eventID)
+		return 0, fmt.Errorf("%s: %w", op, ErrNotEnoughSeats)
+	}
+	if err := checkSeatTypeCapacity(ctx, tx, op, eventID, seatType, seats); err != nil {
+		return 0, err
+	}
+
+	// A concurrent change of the same booking may have won since the lookup
+	res, err = tx.Exec(ctx, `UPDATE bookings SET status = 'confirmed', confirmed_at = CURRENT_TIMESTAMP, version = version + 1 
+                              WHERE id = $1 AND status = 'pending' AND version = $2`, bookingID, version)
+	if err != nil {
+		log.Printf("%s: Failed to update booking status: %v", op, err)
+		return 0, fmt.Errorf("%s: %v", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		if expectsBookingVersion(ctx) {
+			return 0, fmt.Errorf("%s: %w", op, ErrVersionMismatch)
+		}
+		return 0, fmt.Errorf("%s: %w", op, ErrBookingNotFound)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit confirmation: %v", op, err)
+		return 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Successfully confirmed booking for user: %s, event ID: %d", op, userName, eventID)
+	return bookingID, nil
+}
+
+// checkSeatTypeCapacity makes sure confirming seats of seatType keeps that
+// type within its total. Callers hold the event row lock, so confirmations
+// of one event are checked one at a time.
+func checkSeatTypeCapacity(ctx context.Context, tx pgx.Tx, op string, eventID int, seatType string, seats int) error {
+	if seatType == "" {
+		return nil
+	}
+
+	var left int64
+	err := tx.QueryRow(ctx, `SELECT st.total::bigint - COALESCE((SELECT SUM(b.seats) FROM bookings b 
+                                                             WHERE b.event_id = st.event_id AND b.seat_type = st.type 
+                                                               AND b.status = 'confirmed'), 0)
+                             FROM seat_types st WHERE st.event_id = $1 AND st.type = $2`, eventID, seatType).Scan(&left)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Seat type %q is no longer offered by event %d", op, seatType, eventID)
+		return fmt.Errorf("%s: %w", op, ErrInvalidSeatType)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to check seat type %q of event %d: %v", op, seatType, eventID, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	if int64(seats) > left {
+		log.Printf("%s: Not enough %q seats - Available: %d, Requested: %d, Event: %d", op, seatType, left, seats, eventID)
+		return fmt.Errorf("%s: %w", op, ErrNotEnoughSeats)
+	}
+	return nil
+}
+
+// lockEventThenBooking locks the booking found by cond, with arg as $1, after
+// its event and the others given, taking the events in ID order. Confirming
+// locks the event before the booking too, so paths that need both can't
+// deadlock with it or with each other. It returns the booking's event ID, or
+// pgx.ErrNoRows when there is no such booking.
+func lockEventThenBooking(ctx context.Context, tx pgx.Tx, cond string, arg any, others ...int) (int, error) {
+	var eventID int
+	if err := tx.QueryRow(ctx, `SELECT event_id FROM bookings WHERE `+cond, arg).Scan(&eventID); err != nil {
+		return 0, err
+	}
+	ids := append([]int{eventID}, others...)
+	if _, err := tx.Exec(ctx, `SELECT 1 FROM events WHERE id = ANY($1) ORDER BY id FOR UPDATE`, ids); err != nil {
+		return 0, err
+	}
+	var bookingID int
+	if err := tx.QueryRow(ctx, `SELECT id FROM bookings WHERE `+cond+` FOR UPDATE`, arg).Scan(&bookingID); err != nil {
+		return 0, err
+	}
+	return eventID, nil
+}
+
+// confirmFailure tells a wrong token and an expired or cancelled hold apart
+// from a missing pending booking.
+func (s *Storage) confirmFailure(ctx context.Context, op string, eventID int, userName, token string) error {
+	var pending, held, cancelled bool
+	err := s.retryRead(ctx, op, func() error {
+		return s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM bookings 
+                                     WHERE event_id = $1 AND user_name = $2 AND status = 'pending'),
+                                     EXISTS (SELECT 1 FROM bookings 
+                                     WHERE event_id = $1 AND user_name = $2 AND status = 'pending' AND confirm_token_hash = $3),
+                                     EXISTS (SELECT 1 FROM bookings 
+                                     WHERE event_id = $1 AND user_name = $2 AND status = 'cancelled' AND confirm_token_hash = $3)`,
+			eventID, userName, hashConfirmToken(token)).Scan(&pending, &held, &cancelled)
+	})
+	if err != nil {
+		log.Printf("%s: Failed to check pending bookings: %v", op, err)
+		return err
+	}
+	// The token matches, so the lookup only failed on the hold
+	if held {
+		log.Printf("%s: Hold has expired for user: %s, event ID: %d", op, userName, eventID)
+		return ErrBookingExpired
+	}
+	if pending {
+		log.Printf("%s: Confirm token mismatch for user: %s, event ID: %d", op, userName, eventID)
+		return ErrInvalidToken
+	}
+	// Paying for a booking the cleanup already cancelled; it has to be booked again
+	if cancelled {
+		log.Printf("%s: Booking was cancelled for user: %s, event ID: %d", op, userName, eventID)
+		return ErrBookingExpired
+	}
+	log.Printf("%s: No pending booking found for user: %s, event ID: %d", op, userName, eventID)
+	return ErrBookingNotFound
+}
+
+func (s *Storage) ConfirmPartial(ctx context.Context, eventID int, userName, token string, seats int) (*models.Booking, error) {
+	const op = "storage.ConfirmPartial"
+
+	log.Printf("%s: Confirming %d seats for user: %s, event ID: %d", op, seats, userName, eventID)
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Lock the event first so the capacity check can't race another confirmation
+	var available int64
+	err = tx.QueryRow(ctx, `SELECT e.total_seats::bigint - e.confirmed_seats - `+reservedSeats+` FROM events e WHERE e.id = $1 FOR UPDATE`,
+		eventID).Scan(&available)
+	if err != nil {
+		log.Printf("%s: Failed to check available seats for event %d: %v", op, eventID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	var booking models.Booking
+	err = scanBooking(tx.QueryRow(ctx, `SELECT `+bookingColumns+` 
+                            FROM bookings b
+                            WHERE event_id = $1 AND user_name = $2 AND status = 'pending' 
+                              AND confirm_token_hash = $3 AND `+holdNotExpired+`
+                            ORDER BY created_at DESC, id DESC
+                            LIMIT 1
+                            FOR UPDATE`, eventID, userName, hashConfirmToken(token)), &booking)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, s.confirmFailure(ctx, op, eventID, userName, token))
+	}
+	if err != nil {
+		log.Printf("%s: Failed to load pending booking: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	if err := checkBookingVersion(ctx, op, booking.ID, booking.Version); err != nil {
+		return nil, err
+	}
+
+	if seats > booking.Seats {
+		log.Printf("%s: Requested %d seats but booking %d holds %d", op, seats, booking.ID, booking.Seats)
+		return nil, fmt.Errorf("%s: %w", op, ErrSeatsExceedHold)
+	}
+	if int64(seats) > available {
+		log.Printf("%s: Not enough seats - Available: %d, Requested: %d, Event: %d", op, available, seats, eventID)
+		return nil, fmt.Errorf("%s: %w", op, ErrNotEnoughSeats)
+	}
+	if err := checkSeatTypeCapacity(ctx, tx, op, eventID, booking.SeatType, seats); err != nil {
+		return nil, err
+	}
+
+	remainder := booking.Seats - seats
+	err = tx.QueryRow(ctx, `UPDATE bookings SET seats = $1, status = 'confirmed', confirmed_at = CURRENT_TIMESTAMP, version = version + 1 
+                             WHERE id = $2 RETURNING confirmed_at, version`, seats, booking.ID).Scan(&booking.ConfirmedAt, &booking.Version)
+	if err != nil {
+		log.Printf("%s: Failed to confirm booking %d: %v", op, booking.ID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	_, err = tx.Exec(ctx, `UPDATE events SET confirmed_seats = confirmed_seats + $1 WHERE id = $2`, seats, eventID)
+	if err != nil {
+		log.Printf("%s: Failed to count confirmed seats for event %d: %v", op, eventID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	// Keep the released seats as a cancelled row so the original hold stays traceable
+	if remainder > 0 {
+		_, err = tx.Exec(ctx, `INSERT INTO bookings (event_id, user_name, seats, status, cancel_reason, seat_type, created_at) 
+                               VALUES ($1, $2, $3, 'cancelled', 'released', NULLIF($4, ''), $5)`,
+			eventID, userName, remainder, booking.SeatType, booking.CreatedAt)
+		if err != nil {
+			log.Printf("%s: Failed to record released seats: %v", op, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit partial confirmation: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	booking.Seats = seats
+	booking.Status = models.BookingConfirmed
+
+	log.Printf("%s: Confirmed %d seats and released %d for booking %d", op, seats, remainder, booking.ID)
+	return &booking, nil
+}
+
+func (s *Storage) ExtendBookingByOrganizer(ctx context.Context, bookingID, organizerID, extraMinutes int) (time.Time, error) {
+	const op = "storage.ExtendBookingByOrganizer"
+
+	log.Printf("%s: Organizer %d extending booking %d by %d minutes", op, organizerID, bookingID, extraMinutes)
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return time.Time{}, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var status models.BookingStatus
+	var version int
+	var eventOrganizer *int
+	err = tx.QueryRow(ctx, `SELECT b.status, b.version, e.organizer_id 
+                            FROM bookings b
+                            JOIN events e ON e.id = b.event_id
+                            WHERE b.id = $1
+                            FOR UPDATE OF b`, bookingID).Scan(&status, &version, &eventOrganizer)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Booking %d not found", op, bookingID)
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrBookingNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to load booking %d: %v", op, bookingID, err)
+		return time.Time{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	if eventOrganizer == nil || *eventOrganizer != organizerID {
+		log.Printf("%s: Organizer %d does not own the event of booking %d", op, organizerID, bookingID)
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrNotOrganizer)
+	}
+	if err := checkBookingVersion(ctx, op, bookingID, version); err != nil {
+		return time.Time{}, err
+	}
+	if status != models.BookingPending {
+		log.Printf("%s: Booking %d is %s, not pending", op, bookingID, status)
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrNotPending)
+	}
+
+	var expiresAt time.Time
+	err = tx.QueryRow(ctx, `UPDATE bookings b SET extension_minutes = b.extension_minutes + $1, version = b.version + 1
+                            FROM events e
+                            WHERE b.id = $2 AND e.id = b.event_id
+                            RETURNING `+bookingExpiresAt, extraMinutes, bookingID).Scan(&expiresAt)
+	if err != nil {
+		log.Printf("%s: Failed to extend booking %d: %v", op, bookingID, err)
+		return time.Time{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO booking_extensions (booking_id, organizer_id, extra_minutes, expires_at) 
+                           VALUES ($1, $2, $3, $4)`, bookingID, organizerID, extraMinutes, expiresAt)
+	if err != nil {
+		log.Printf("%s: Failed to record extension of booking %d: %v", op, bookingID, err)
+		return time.Time{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit booking extension: %v", op, err)
+		return time.Time{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Booking %d now expires at %s", op, bookingID, expiresAt.Format("2006-01-02 15:04:05"))
+	return expiresAt, nil
+}
+
+// HeartbeatBooking keeps the hold of a pending booking alive while its
+// holder is paying, pushing its expiry to at least step from now. Heartbeats
+// never push it more than limit past the hold itself, and don't change the
+// booking's version, so they don't invalidate an ETag held for confirming.
+func (s *Storage) HeartbeatBooking(ctx context.Context, reference, token string, step, limit time.Duration) (time.Time, error) {
+	const op = "storage.HeartbeatBooking"
+
+	log.Printf("%s: Heartbeat for booking %s", op, reference)
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return time.Time{}, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var bookingID int
+	var status models.BookingStatus
+	var tokenHash *string
+	var held bool
+	err = tx.QueryRow(ctx, `SELECT b.id, b.status, b.confirm_token_hash, `+bookingExpiresAt+` >= NOW()
+                            FROM bookings b
+                            JOIN events e ON e.id = b.event_id
+                            WHERE b.reference = $1
+                            FOR UPDATE OF b`, reference).Scan(&bookingID, &status, &tokenHash, &held)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Booking %s not found", op, reference)
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrBookingNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to load booking %s: %v", op, reference, err)
+		return time.Time{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	if tokenHash == nil || *tokenHash != hashConfirmToken(token) {
+		log.Printf("%s: Invalid token for booking %d", op, bookingID)
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+	if status != models.BookingPending {
+		log.Printf("%s: Booking %d is %s, not pending", op, bookingID, status)
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrNotPending)
+	}
+	// A lapsed hold's seats may already be taken, so it can't be revived
+	if !held {
+		log.Printf("%s: Hold of booking %d has expired", op, bookingID)
+		return time.Time{}, fmt.Errorf("%s: %w", op, ErrBookingExpired)
+	}
+
+	var expiresAt time.Time
+	err = tx.QueryRow(ctx, `UPDATE bookings b 
+                            SET heartbeat_until = GREATEST(b.heartbeat_until, 
+                                LEAST(NOW() + $1 * interval '1 second', `+bookingHoldEnd+` + $2 * interval '1 second'))
+                            FROM events e
+                            WHERE b.id = $3 AND e.id = b.event_id
+                            RETURNING `+bookingExpiresAt, step.Seconds(), limit.Seconds(), bookingID).Scan(&expiresAt)
+	if err != nil {
+		log.Printf("%s: Failed to push expiry of booking %d: %v", op, bookingID, err)
+		return time.Time{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit heartbeat: %v", op, err)
+		return time.Time{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Booking %d now expires at %s", op, bookingID, expiresAt.Format("2006-01-02 15:04:05"))
+	return expiresAt, nil
+}
+
+// MoveBooking replaces a booking with one for the same user and seats on
+// targetEventID, cancelling the original, provided the organizer owns both
+// events and the seats fit on the target. Confirmed bookings stay confirmed;
+// pending ones get a fresh hold on the target and keep their confirm token.
+// It returns the cancelled original and its replacement.
+func (s *Storage) MoveBooking(ctx context.Context, bookingID, organizerID, targetEventID int) (*models.Booking, *models.Booking, error) {
+	const op = "storage.MoveBooking"
+
+	log.Printf("%s: Organizer %d moving booking %d to event %d", op, organizerID, bookingID, targetEventID)
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	sourceEventID, err := lockEventThenBooking(ctx, tx, `id = $1`, bookingID, targetEventID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Booking %d not found", op, bookingID)
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrBookingNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to lock booking %d: %v", op, bookingID, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
+	if sourceEventID == targetEventID {
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrSameEvent)
+	}
+
+	organizers := make(map[int]*int, 2)
+	var onePerUser bool
+	rows, err := tx.Query(ctx, `SELECT id, organizer_id, one_booking_per_user FROM events 
+                                WHERE id IN ($1, $2)`, sourceEventID, targetEventID)
+	if err != nil {
+		log.Printf("%s: Failed to load events: %v", op, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
+	for rows.Next() {
+		var id int
+		var organizerID *int
+		var onePer bool
+		if err := rows.Scan(&id, &organizerID, &onePer); err != nil {
+			rows.Close()
+			log.Printf("%s: Failed to scan event row: %v", op, err)
+			return nil, nil, fmt.Errorf("%s: %v", op, err)
+		}
+		organizers[id] = organizerID
+		if id == targetEventID {
+			onePerUser = onePer
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to load events: %v", op, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
+	if _, ok := organizers[targetEventID]; !ok {
+		log.Printf("%s: Target event %d not found", op, targetEventID)
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrEventNotFound)
+	}
+	for _, id := range []int{sourceEventID, targetEventID} {
+		if owner := organizers[id]; owner == nil || *owner != organizerID {
+			log.Printf("%s: Organizer %d does not own event %d", op, organizerID, id)
+			return nil, nil, fmt.Errorf("%s: %w", op, ErrNotOrganizer)
+		}
+	}
+
+	var original models.Booking
+	var tokenHash *string
+	var holdActive bool
+	err = scanBooking(tx.QueryRow(ctx, `SELECT `+bookingColumns+`, confirm_token_hash, `+holdNotExpired+` 
+                                         FROM bookings b WHERE id = $1`,
+		bookingID), &original, &tokenHash, &holdActive)
+	if err != nil {
+		log.Printf("%s: Failed to load booking %d: %v", op, bookingID, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	switch {
+	case original.Status == models.BookingCancelled:
+		log.Printf("%s: Booking %d is cancelled", op, bookingID)
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrBookingCancelled)
+	case original.CheckedInAt != nil:
+		log.Printf("%s: Booking %d is already checked in", op, bookingID)
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrCheckedIn)
+	case original.Status == models.BookingPending && !holdActive:
+		log.Printf("%s: Hold of booking %d has expired", op, bookingID)
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrBookingExpired)
+	}
+
+	if onePerUser {
+		var exists bool
+		err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM bookings 
+                                WHERE event_id = $1 AND user_name = $2 AND status <> 'cancelled')`,
+			targetEventID, original.UserName).Scan(&exists)
+		if err != nil {
+			log.Printf("%s: Failed to check existing bookings of user %s: %v", op, original.UserName, err)
+			return nil, nil, fmt.Errorf("%s: %v", op, err)
+		}
+		if exists {
+			log.Printf("%s: User %s already has a booking for event %d", op, original.UserName, targetEventID)
+			return nil, nil, fmt.Errorf("%s: %w", op, ErrDuplicateBooking)
+		}
+	}
+
+	// Check capacity the way a new booking would, pending holds included, and
+	// per seat type when the target has them
+	var available int64
+	var typeAvailable *int64
+	var hasTypes bool
+	var hideExactBelow int
+	err = tx.QueryRow(ctx, `
+        SELECT e.total_seats::bigint - COALESCE((SELECT SUM(seats) FROM bookings 
+                                                 WHERE event_id = e.id AND status = 'confirmed'), 0) - `+heldPendingSeats+` - `+reservedSeats+`,
+               EXISTS (SELECT 1 FROM seat_types WHERE event_id = e.id),
+               (SELECT st.total::bigint - `+seatTypeTaken+` FROM seat_types st WHERE st.event_id = e.id AND st.type = $2),
+               e.hide_exact_below
+        FROM events e WHERE e.id = $1`,
+		targetEventID, original.SeatType).Scan(&available, &hasTypes, &typeAvailable, &hideExactBelow)
+	if err != nil {
+		log.Printf("%s: Failed to check available seats for event %d: %v", op, targetEventID, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
+	switch {
+	case hasTypes && typeAvailable == nil, !hasTypes && original.SeatType != "":
+		log.Printf("%s: Seat type %q is not offered by event %d", op, original.SeatType, targetEventID)
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrInvalidSeatType)
+	case hasTypes:
+		available = min(available, *typeAvailable)
+	}
+	if available < int64(original.Seats) {
+		log.Printf("%s: Not enough seats on event %d - Available: %d, Required: %d", op, targetEventID, available, original.Seats)
+		return nil, nil, fmt.Errorf("%s: %w", op, &ShortfallError{Requested: int64(original.Seats), Available: available, HideExactBelow: hideExactBelow})
+	}
+
+	moved := models.Booking{
+		EventID:     targetEventID,
+		UserName:    original.UserName,
+		Seats:       original.Seats,
+		SeatType:    original.SeatType,
+		ConfirmedAt: original.ConfirmedAt,
+	}
+	err = tx.QueryRow(ctx, `INSERT INTO bookings (event_id, user_name, seats, status, confirm_token_hash, seat_type, one_per_user, confirmed_at, expires_at) 
+                            VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8, `+newBookingExpiresAt+`) 
+                            RETURNING id, status, reference, created_at, version`,
+		targetEventID, moved.UserName, moved.Seats, original.Status, tokenHash, moved.SeatType, onePerUser, moved.ConfirmedAt,
+	).Scan(&moved.ID, &moved.Status, &moved.Reference, &moved.CreatedAt, &moved.Version)
+	if isUniqueViolation(err, onePerUserConstraint) {
+		log.Printf("%s: User %s already has a booking for event %d", op, moved.UserName, targetEventID)
+		return nil, nil, fmt.Errorf("%s: %w", op, ErrDuplicateBooking)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to insert moved booking: %v", op, err)
+		return nil, nil, fmt.Errorf("%s: %w", op, translateConstraint(err))
+	}
+
+	if original.Status == models.BookingConfirmed {
+		_, err = tx.Exec(ctx, `UPDATE events SET confirmed_seats = confirmed_seats + CASE id WHEN $1 THEN -$3::int ELSE $3::int END 
+                               WHERE id IN ($1, $2)`, sourceEventID, targetEventID, original.Seats)
+		if err != nil {
+			log.Printf("%s: Failed to move confirmed seats: %v", op, err)
+			return nil, nil, fmt.Errorf("%s: %v", op, err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE bookings SET status = 'cancelled', cancel_reason = $1, version = version + 1 WHERE id = $2`,
+		models.CancelReasonMoved, bookingID)
+	if err != nil {
+		log.Printf("%s: Failed to cancel booking %d: %v", op, bookingID, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit booking move: %v", op, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Moved booking %d of event %d to booking %d of event %d",
+		op, bookingID, sourceEventID, moved.ID, targetEventID)
+	original.Status = models.BookingCancelled
+	original.CancelReason = models.CancelReasonMoved
+	original.Version++
+	return &original, &moved, nil
+}
+
+func (s *Storage) GetEventBookings(ctx context.Context, eventID int) ([]models.Booking, error) {
+	const op = "storage.GetEventBookings"
+
+	log.Printf("%s: Retrieving bookings for event ID: %d", op, eventID)
+
+	query := `SELECT ` + bookingColumns + ` 
+              FROM bookings WHERE event_id = $1
+              ORDER BY created_at ASC, id ASC`
+
+	rows, err := s.queryRead(ctx, op, query, eventID)
+	if err != nil {
+		log.Printf("%s: Failed to query bookings for event %d: %v", op, eventID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	var bookings []models.Booking
+	for rows.Next() {
+		// Stop scanning once the caller has gone away; the deferred Close releases the connection
+		if err := ctx.Err(); err != nil {
+			log.Printf("%s: Interrupted after %d bookings for event %d: %v", op, len(bookings), eventID, err)
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		var b models.Booking
+		err := scanBooking(rows, &b)
+		if err != nil {
+			log.Printf("%s: Failed to scan booking row: %v", op, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+		bookings = append(bookings, b)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate booking rows: %v", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Printf("%s: Retrieved %d bookings for event ID: %d", op, len(bookings), eventID)
+	return bookings, nil
+}
+
+// GetEventBookingsAfter returns up to limit of an event's bookings with IDs
+// above afterID, in ID order. next is the afterID of the following page and
+// zero on the last one.
+func (s *Storage) GetEventBookingsAfter(ctx context.Context, eventID, afterID, limit int) ([]models.Booking, int, error) {
+	const op = "storage.GetEventBookingsAfter"
+
+	log.Printf("%s: Retrieving up to %d bookings after ID %d for event ID: %d", op, limit, afterID, eventID)
+
+	// Fetch one extra row to learn whether another page follows
+	query := `SELECT ` + bookingColumns + `
+              FROM bookings WHERE event_id = $1 AND id > $2
+              ORDER BY id ASC LIMIT $3`
+
+	rows, err := s.queryRead(ctx, op, query, eventID, afterID, limit+1)
+	if err != nil {
+		log.Printf("%s: Failed to query bookings for event %d: %v", op, eventID, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	bookings := []models.Booking{}
+	for rows.Next() {
+		var b models.Booking
+		if err := scanBooking(rows, &b); err != nil {
+			log.Printf("%s: Failed to scan booking row: %v", op, err)
+			return nil, 0, fmt.Errorf("%s: %v", op, err)
+		}
+		bookings = append(bookings, b)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate booking rows: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	next := 0
+	if len(bookings) > limit {
+		bookings = bookings[:limit]
+		next = bookings[len(bookings)-1].ID
+	}
+
+	log.Printf("%s: Retrieved %d bookings for event ID %d, has next page: %t", op, len(bookings), eventID, next != 0)
+	return bookings, next, nil
+}
+
+// GetEventBookingsInRange returns an event's bookings created between from
+// and to, inclusive. A zero bound leaves that side open and an empty status
+// matches every booking.
+func (s *Storage) GetEventBookingsInRange(ctx context.Context, eventID int, from, to time.Time, status models.BookingStatus) ([]models.Booking, error) {
+	const op = "storage.GetEventBookingsInRange"
+
+	log.Printf("%s: Retrieving bookings for event ID: %d, from: %s, to: %s, status: %q",
+		op, eventID, from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339), status)
+
+	args := []any{eventID}
+	conds := []string{"event_id = $1"}
+	switch {
+	case !from.IsZero() && !to.IsZero():
+		args = append(args, from.UTC(), to.UTC())
+		conds = append(conds, "created_at BETWEEN $2 AND $3")
+	case !from.IsZero():
+		args = append(args, from.UTC())
+		conds = append(conds, "created_at >= $2")
+	case !to.IsZero():
+		args = append(args, to.UTC())
+		conds = append(conds, "created_at <= $2")
+	}
+	if status != "" {
+		args = append(args, status)
+		conds = append(conds, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	query := `SELECT ` + bookingColumns + ` FROM bookings` + whereClause(conds) + `
+              ORDER BY created_at ASC, id ASC`
+
+	rows, err := s.queryRead(ctx, op, query, args...)
+	if err != nil {
+		log.Printf("%s: Failed to query bookings for event %d: %v", op, eventID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	bookings := []models.Booking{}
+	for rows.Next() {
+		var b models.Booking
+		if err := scanBooking(rows, &b); err != nil {
+			log.Printf("%s: Failed to scan booking row: %v", op, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+		bookings = append(bookings, b)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate booking rows: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Retrieved %d bookings for event ID: %d", op, len(bookings), eventID)
+	return bookings, nil
+}
+
+func (s *Storage) GetUserBookings(ctx context.Context, userName string, status models.BookingStatus, limit, offset int) ([]models.Booking, int, error) {
+	const op = "storage.GetUserBookings"
+
+	log.Printf("%s: Retrieving bookings for user: %s, status: %q, limit: %d, offset: %d",
+		op, userName, status, limit, offset)
+
+	// Empty status matches every booking of the user
+	var total int
+	err := s.retryRead(ctx, op, func() error {
+		return s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM bookings 
+                                     WHERE user_name = $1 AND ($2 = '' OR status = $2)`,
+			userName, status).Scan(&total)
+	})
+	if err != nil {
+		log.Printf("%s: Failed to count bookings for user %s: %v", op, userName, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	query := `SELECT ` + bookingColumns + ` 
+              FROM bookings 
+              WHERE user_name = $1 AND ($2 = '' OR status = $2)
+              ORDER BY created_at DESC, id DESC
+              LIMIT $3 OFFSET $4`
+
+	rows, err := s.queryRead(ctx, op, query, userName, status, limit, offset)
+	if err != nil {
+		log.Printf("%s: Failed to query bookings for user %s: %v", op, userName, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	bookings := []models.Booking{}
+	for rows.Next() {
+		var b models.Booking
+		err := scanBooking(rows, &b)
+		if err != nil {
+			log.Printf("%s: Failed to scan booking row: %v", op, err)
+			return nil, 0, fmt.Errorf("%s: %v", op, err)
+		}
+		bookings = append(bookings, b)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate booking rows: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Retrieved %d of %d bookings for user: %s", op, len(bookings), total, userName)
+	return bookings, total, nil
+}
+
+// GetUnbookedEvents returns upcoming events the user holds no pending or
+// confirmed booking for, soonest first, along with how many there are.
+func (s *Storage) GetUnbookedEvents(ctx context.Context, userName string, limit, offset int) ([]models.Event, int, error) {
+	const op = "storage.GetUnbookedEvents"
+
+	log.Printf("%s: Retrieving unbooked events for user: %s, limit: %d, offset: %d", op, userName, limit, offset)
+
+	conds, args := eventFilterConds(models.EventFilter{}, []any{userName})
+	conds = append(conds, `NOT EXISTS (
+            SELECT 1 FROM bookings b
+            WHERE b.event_id = events.id AND b.user_name = $1 AND b.status <> 'cancelled'
+        )`)
+	where := whereClause(conds)
+
+	var total int
+	err := s.retryRead(ctx, op, func() error {
+		return s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM events`+where, args...).Scan(&total)
+	})
+	if err != nil {
+		log.Printf("%s: Failed to count unbooked events for user %s: %v", op, userName, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	args = append(args, limit, offset)
+	query := `SELECT ` + eventColumns + ` FROM events` + where +
+		fmt.Sprintf(` ORDER BY date ASC, id ASC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := s.queryRead(ctx, op, query, args...)
+	if err != nil {
+		log.Printf("%s: Failed to query unbooked events for user %s: %v", op, userName, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	events := []models.Event{}
+	for rows.Next() {
+		var event models.Event
+		if err := scanEvent(rows, &event); err != nil {
+			log.Printf("%s: Failed to scan event row: %v", op, err)
+			return nil, 0, fmt.Errorf("%s: %v", op, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate event rows: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Retrieved %d of %d unbooked events for user: %s", op, len(events), total, userName)
+	return events, total, nil
+}
+
+// SetBookingStatus moves a booking to a new status if the transition is
+// allowed, keeping the event's confirmed seat count in step.
+func (s *Storage) SetBookingStatus(ctx context.Context, bookingID int, to models.BookingStatus) error {
+	const op = "storage.SetBookingStatus"
+
+	log.Printf("%s: Setting booking %d to %s", op, bookingID, to)
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	eventID, err := lockEventThenBooking(ctx, tx, `id = $1`, bookingID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Booking %d not found", op, bookingID)
+		return fmt.Errorf("%s: %w", op, ErrBookingNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to lock booking %d: %v", op, bookingID, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+
+	var from models.BookingStatus
+	var seats int
+	err = tx.QueryRow(ctx, `SELECT status, seats FROM bookings WHERE id = $1`, bookingID).Scan(&from, &seats)
+	if err != nil {
+		log.Printf("%s: Failed to load booking %d: %v", op, bookingID, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+
+	if err := models.ValidateTransition(from, to); err != nil {
+		log.Printf("%s: Rejected transition for booking %d: %v", op, bookingID, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	delta := 0
+	if to == models.BookingConfirmed {
+		delta = seats
+	} else if from == models.BookingConfirmed {
+		delta = -seats
+	}
+	if delta != 0 {
+		res, err := tx.Exec(ctx, `UPDATE events e SET confirmed_seats = e.confirmed_seats + $1 
+                                  WHERE e.id = $2 AND e.confirmed_seats::bigint + $1 + `+reservedSeats+` <= e.total_seats`, delta, eventID)
+		if err != nil {
+			log.Printf("%s: Failed to update confirmed seats for event %d: %v", op, eventID, err)
+			return fmt.Errorf("%s: %v", op, err)
+		}
+		if res.RowsAffected() == 0 {
+			log.Printf("%s: Not enough seats to confirm booking %d, event: %d", op, bookingID, eventID)
+			return fmt.Errorf("%s: %w", op, ErrNotEnoughSeats)
+		}
+	}
+
+	// Cancelled bookings keep confirmed_at so refunds still show when they were paid
+	_, err = tx.Exec(ctx, `UPDATE bookings 
+                           SET status = $1,
+                               confirmed_at = CASE $1 WHEN 'confirmed' THEN CURRENT_TIMESTAMP 
+                                                      WHEN 'pending' THEN NULL 
+                                                      ELSE confirmed_at END,
+                               version = version + 1
+                           WHERE id = $2`, to, bookingID)
+	if err != nil {
+		log.Printf("%s: Failed to update booking %d: %v", op, bookingID, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit status change: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Booking %d moved from %s to %s", op, bookingID, from, to)
+	return nil
+}
+
+// CheckIn marks a confirmed booking as attended and returns it.
+func (s *Storage) CheckIn(ctx context.Context, reference string) (*models.Booking, error) {
+	const op = "storage.CheckIn"
+
+	log.Printf("%s: Checking in booking with reference: %s", op, reference)
+
+	var booking models.Booking
+	err := scanBooking(s.pool.QueryRow(ctx, `UPDATE bookings SET checked_in_at = CURRENT_TIMESTAMP 
+                                             WHERE reference = $1 AND status = 'confirmed' AND checked_in_at IS NULL
+                                             RETURNING `+bookingColumns, reference), &booking)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, s.checkInFailure(ctx, op, reference))
+	}
+	if err != nil {
+		log.Printf("%s: Failed to check in booking %s: %v", op, reference, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Checked in booking ID: %d", op, booking.ID)
+	return &booking, nil
+}
+
+// checkInFailure explains why CheckIn matched no booking.
+func (s *Storage) checkInFailure(ctx context.Context, op, reference string) error {
+	booking, err := s.GetBookingByReference(ctx, reference)
+	if err != nil {
+		return err
+	}
+	if booking.Status != models.BookingConfirmed {
+		log.Printf("%s: Booking %d is %s, not confirmed", op, booking.ID, booking.Status)
+		return ErrNotConfirmed
+	}
+	log.Printf("%s: Booking %d already checked in", op, booking.ID)
+	return ErrCheckedIn
+}
+
+// CancelBooking cancels a pending booking at its holder's request; the
+// confirm token proves the caller holds it.
+func (s *Storage) CancelBooking(ctx context.Context, reference, token string) (*models.Booking, error) {
+	const op = "storage.CancelBooking"
+	if token == "" {
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+	return s.cancelBooking(ctx, op, reference, token, models.BookingPending, models.CancelReasonUserRequest)
+}
+
+// RefundBooking cancels a confirmed booking and returns its seats to the event.
+func (s *Storage) RefundBooking(ctx context.Context, reference string) (*models.Booking, error) {
+	const op = "storage.RefundBooking"
+	return s.cancelBooking(ctx, op, reference, "", models.BookingConfirmed, models.CancelReasonRefunded)
+}
+
+// cancelBooking cancels the booking with reference if it is in status from,
+// checking token against the stored hash when one is given.
+func (s *Storage) cancelBooking(ctx context.Context, op, reference, token string, from models.BookingStatus, reason models.CancelReason) (*models.Booking, error) {
+	log.Printf("%s: Cancelling booking %s, reason: %s", op, reference, reason)
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	eventID, err := lockEventThenBooking(ctx, tx, `reference = $1`, reference)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Booking %s not found", op, reference)
+		return nil, fmt.Errorf("%s: %w", op, ErrBookingNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to lock booking %s: %v", op, reference, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	var pastDeadline bool
+	err = tx.QueryRow(ctx, `SELECT COALESCE(date - cancellation_deadline_hours * interval '1 hour' < NOW(), false) 
+                            FROM events WHERE id = $1`, eventID).Scan(&pastDeadline)
+	if err != nil {
+		log.Printf("%s: Failed to check cancellation deadline of event %d: %v", op, eventID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	var booking models.Booking
+	var tokenHash *string
+	err = scanBooking(tx.QueryRow(ctx, `SELECT `+bookingColumns+`, confirm_token_hash 
+                                         FROM bookings WHERE reference = $1`, reference), &booking, &tokenHash)
+	if err != nil {
+		log.Printf("%s: Failed to load booking %s: %v", op, reference, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	if token != "" && (tokenHash == nil || *tokenHash != hashConfirmToken(token)) {
+		log.Printf("%s: Invalid token for booking %d", op, booking.ID)
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+	if err := checkBookingVersion(ctx, op, booking.ID, booking.Version); err != nil {
+		return nil, err
+	}
+	if booking.Status != from {
+		log.Printf("%s: Booking %d is %s, expected %s", op, booking.ID, booking.Status, from)
+		if from == models.BookingPending {
+			return nil, fmt.Errorf("%s: %w", op, ErrNotPending)
+		}
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfirmed)
+	}
+	// The deadline binds holders only; organizers can still refund
+	if reason == models.CancelReasonUserRequest && pastDeadline {
+		log.Printf("%s: Cancellation window of event %d has closed", op, booking.EventID)
+		return nil, fmt.Errorf("%s: %w", op, ErrCancellationClosed)
+	}
+
+	if from == models.BookingConfirmed {
+		_, err = tx.Exec(ctx, `UPDATE events SET confirmed_seats = confirmed_seats - $1 WHERE id = $2`, booking.Seats, booking.EventID)
+		if err != nil {
+			log.Printf("%s: Failed to release confirmed seats for event %d: %v", op, booking.EventID, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE bookings SET status = 'cancelled', cancel_reason = $1, version = version + 1 WHERE id = $2`, reason, booking.ID)
+	if err != nil {
+		log.Printf("%s: Failed to cancel booking %d: %v", op, booking.ID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit cancellation: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	booking.Status = models.BookingCancelled
+	booking.CancelReason = reason
+	booking.Version++
+
+	log.Printf("%s: Cancelled booking ID: %d, reason: %s", op, booking.ID, reason)
+	return &booking, nil
+}
+
+// JoinWaitlist puts the user at the end of the event's waitlist.
+func (s *Storage) JoinWaitlist(ctx context.Context, eventID int, userName string) (*models.WaitlistEntry, error) {
+	const op = "storage.JoinWaitlist"
+
+	log.Printf("%s: Adding user %s to the waitlist of event %d", op, userName, eventID)
+
+	entry := models.WaitlistEntry{EventID: eventID, UserName: userName}
+	err := s.pool.QueryRow(ctx, `INSERT INTO waitlist_entries (event_id, user_name)
+                                 SELECT id, $2 FROM events WHERE id = $1
+                                 RETURNING id, created_at`, eventID, userName).Scan(&entry.ID, &entry.JoinedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Event %d not found", op, eventID)
+		return nil, fmt.Errorf("%s: %w", op, ErrEventNotFound)
+	}
+	if isUniqueViolation(err, waitlistPendingConstraint) {
+		log.Printf("%s: User %s is already waiting for event %d", op, userName, eventID)
+		return nil, fmt.Errorf("%s: %w", op, ErrAlreadyWaitlisted)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to add user %s to the waitlist of event %d: %v", op, userName, eventID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	entry.Position, err = s.GetWaitlistPosition(ctx, eventID, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("%s: User %s joined the waitlist of event %d at position %d", op, userName, eventID, entry.Position)
+	return &entry, nil
+}
+
+// GetWaitlistPosition returns the user's 1-based place among the entries
+// still waiting for the event, ordered by when they joined.
+func (s *Storage) GetWaitlistPosition(ctx context.Context, eventID int, userName string) (int, error) {
+	const op = "storage.GetWaitlistPosition"
+
+	log.Printf("%s: Retrieving waitlist position of user %s for event %d", op, userName, eventID)
+
+	query := `
+        SELECT position FROM (
+            SELECT user_name, ROW_NUMBER() OVER (ORDER BY created_at, id) AS position
+            FROM waitlist_entries
+            WHERE event_id = $1 AND status = 'pending'
+        ) w
+        WHERE user_name = $2`
+
+	var position int
+	err := s.retryRead(ctx, op, func() error {
+		return s.pool.QueryRow(ctx, query, eventID, userName).Scan(&position)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: User %s is not waiting for event %d", op, userName, eventID)
+		return 0, fmt.Errorf("%s: %w", op, ErrNotWaitlisted)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to get waitlist position of user %s for event %d: %v", op, userName, eventID, err)
+		return 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	return position, nil
+}
+
+// GetBookingForUser returns the user's most recent booking for an event that
+// is not cancelled.
+func (s *Storage) GetBookingForUser(ctx context.Context, eventID int, userName string) (*models.Booking, error) {
+	const op = "storage.GetBookingForUser"
+
+	log.Printf("%s: Retrieving booking of user %s for event ID: %d", op, userName, eventID)
+
+	query := `SELECT ` + bookingColumns + ` 
+              FROM bookings 
+              WHERE event_id = $1 AND user_name = $2 AND status <> 'cancelled'
+              ORDER BY created_at DESC, id DESC
+              LIMIT 1`
+
+	var booking models.Booking
+	err := s.retryRead(ctx, op, func() error {
+		return scanBooking(s.pool.QueryRow(ctx, query, eventID, userName), &booking)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: No booking of user %s for event %d", op, userName, eventID)
+		return nil, fmt.Errorf("%s: %w", op, ErrBookingNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to retrieve booking of user %s for event %d: %v", op, userName, eventID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Found booking ID %d (%s)", op, booking.ID, booking.Status)
+	return &booking, nil
+}
+
+func (s *Storage) GetBookingByReference(ctx context.Context, reference string) (*models.Booking, error) {
+	const op = "storage.GetBookingByReference"
+
+	log.Printf("%s: Retrieving booking with reference: %s", op, reference)
+
+	var booking models.Booking
+	err := s.retryRead(ctx, op, func() error {
+		return scanBooking(s.pool.QueryRow(ctx, `SELECT `+bookingColumns+` FROM bookings WHERE reference = $1`, reference), &booking)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Booking with reference %s not found", op, reference)
+		return nil, fmt.Errorf("%s: %w", op, ErrBookingNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to get booking %s: %v", op, reference, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Retrieved booking ID: %d", op, booking.ID)
+	return &booking, nil
+}
+
+// GetReceipt returns the receipt of the confirmed booking with reference,
+// ErrBookingNotFound when there is none and ErrNotConfirmed while it is
+// pending or after it was cancelled.
+func (s *Storage) GetReceipt(ctx context.Context, reference string) (*models.Receipt, error) {
+	const op = "storage.GetReceipt"
+
+	log.Printf("%s: Retrieving receipt for booking reference: %s", op, reference)
+
+	query := `SELECT b.reference, b.status, e.id, e.name, e.date, COALESCE(e.timezone, ''), b.user_name, b.seats,
+                     COALESCE(b.seat_type, ''), st.price, b.confirmed_at
+              FROM bookings b
+              JOIN events e ON e.id = b.event_id
+              LEFT JOIN seat_types st ON st.event_id = b.event_id AND st.type = b.seat_type
+              WHERE b.reference = $1`
+
+	var receipt models.Receipt
+	var status models.BookingStatus
+	var timezone string
+	var confirmedAt *time.Time
+	err := s.retryRead(ctx, op, func() error {
+		return s.pool.QueryRow(ctx, query, reference).Scan(&receipt.Reference, &status, &receipt.EventID, &receipt.EventName,
+			&receipt.EventDate, &timezone, &receipt.UserName, &receipt.Seats, &receipt.SeatType, &receipt.UnitPrice, &confirmedAt)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Booking with reference %s not found", op, reference)
+		return nil, fmt.Errorf("%s: %w", op, ErrBookingNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to get receipt for %s: %v", op, reference, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	if status != models.BookingConfirmed || confirmedAt == nil {
+		log.Printf("%s: Booking %s is %s, no receipt", op, reference, status)
+		return nil, fmt.Errorf("%s: %w", op, ErrNotConfirmed)
+	}
+
+	receipt.ConfirmedAt = *confirmedAt
+	if receipt.UnitPrice != nil {
+		total := int64(*receipt.UnitPrice) * int64(receipt.Seats)
+		receipt.TotalPrice = &total
+	}
+	// Shown in the event's own timezone, like the event itself
+	if loc, err := models.LoadTimezone(timezone); err == nil {
+		receipt.EventDate = receipt.EventDate.In(loc)
+	}
+
+	log.Printf("%s: Retrieved receipt for booking %s", op, reference)
+	return &receipt, nil
+}
+
+func (s *Storage) GetBookingStates(ctx context.Context, ids []int) ([]models.BookingState, error) {
+	const op = "storage.GetBookingStates"
+
+	log.Printf("%s: Retrieving states of %d bookings", op, len(ids))
+
+	query := `SELECT b.id, b.status,
+                     CASE WHEN b.status = 'pending' THEN ` + bookingExpiresAt + ` END
+              FROM bookings b
+              JOIN events e ON e.id = b.event_id
+              WHERE b.id = ANY($1)
+              ORDER BY b.id`
+
+	rows, err := s.queryRead(ctx, op, query, ids)
+	if err != nil {
+		log.Printf("%s: Failed to query booking states: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	states := []models.BookingState{}
+	for rows.Next() {
+		var state models.BookingState
+		err := rows.Scan(&state.ID, &state.Status, &state.ExpiresAt)
+		if err != nil {
+			log.Printf("%s: Failed to scan booking state row: %v", op, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+		states = append(states, state)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate booking state rows: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Found %d of %d bookings", op, len(states), len(ids))
+	return states, nil
+}
+
+func (s *Storage) GetExpiredPending(ctx context.Context) ([]models.ExpiredBooking, error) {
+	const op = "storage.GetExpiredPending"
+
+	log.Printf("%s: Retrieving expired pending bookings", op)
+
+	query := `SELECT b.id, b.event_id, b.user_name, b.seats, b.status, COALESCE(b.seat_type, ''), b.reference, b.created_at,
+                     b.confirmed_at, b.checked_in_at, COALESCE(b.cancel_reason, ''), ` + bookingExpiresAt + `
+              FROM bookings b
+              JOIN events e ON e.id = b.event_id
+              WHERE b.status = 'pending' AND ` + bookingExpiresAt + ` < NOW()
+              ORDER BY 12 ASC, b.id ASC`
+
+	rows, err := s.queryRead(ctx, op, query)
+	if err != nil {
+		log.Printf("%s: Failed to query expired bookings: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	bookings := []models.ExpiredBooking{}
+	for rows.Next() {
+		var b models.ExpiredBooking
+		err := scanBooking(rows, &b.Booking, &b.ExpiresAt)
+		if err != nil {
+			log.Printf("%s: Failed to scan expired booking row: %v", op, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+		bookings = append(bookings, b)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate expired booking rows: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Found %d expired pending bookings", op, len(bookings))
+	return bookings, nil
+}
+
+// CancelExpiredBookings cancels pending bookings whose hold has passed by
+// more than the expiry skew. It returns the affected event IDs and how many
+// bookings it cancelled.
+func (s *Storage) CancelExpiredBookings(ctx context.Context) ([]int, int64, error) {
+	const op = "storage.CancelExpiredBookings"
+
+	log.Printf("%s: Starting expired bookings cleanup", op)
+
+	// All batches share one transaction so a cancelled run leaves nothing half-cancelled
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(context.Background())
+
+	// Report which events were affected so callers can invalidate per-event state
+	query := `WITH expired AS (
+                  SELECT b.id FROM bookings b
+                  JOIN events e ON b.event_id = e.id
+                  WHERE b.status = 'pending'
+                  AND ` + bookingExpiresAt + ` < NOW() - $2 * interval '1 second'
+                  ORDER BY b.id
+                  LIMIT $1
+                  FOR UPDATE OF b SKIP LOCKED
+              ), cancelled AS (
+                  UPDATE bookings
+                  SET status = 'cancelled', cancel_reason = 'expired', version = bookings.version + 1
+                  FROM expired
+                  WHERE bookings.id = expired.id
+                  RETURNING bookings.event_id
+              )
+              SELECT event_id, COUNT(*) FROM cancelled GROUP BY event_id`
+
+	affected := make(map[int]bool)
+	var cancelledCount int64
+	for batch := 1; ; batch++ {
+		if err := ctx.Err(); err != nil {
+			log.Printf("%s: Cleanup interrupted before batch %d, rolling back: %v", op, batch, err)
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		batchCount, err := cancelExpiredBatch(ctx, tx, query, s.cleanupBatchSize, s.expirySkew, affected)
+		if err != nil {
+			log.Printf("%s: Failed to cancel expired bookings in batch %d: %v", op, batch, err)
+			return nil, 0, fmt.Errorf("%s: %w", op, err)
+		}
+		cancelledCount += batchCount
+
+		if batchCount < int64(s.cleanupBatchSize) {
+			break
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit cleanup transaction: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	eventIDs := make([]int, 0, len(affected))
+	for id := range affected {
+		eventIDs = append(eventIDs, id)
+	}
+	slices.Sort(eventIDs)
+
+	log.Printf("%s: Cancelled %d expired bookings across %d events", op, cancelledCount, len(eventIDs))
+	return eventIDs, cancelledCount, nil
+}
+
+func cancelExpiredBatch(ctx context.Context, tx pgx.Tx, query string, batchSize int, skew time.Duration, affected map[int]bool) (int64, error) {
+	rows, err := tx.Query(ctx, query, batchSize, skew.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var total int64
+	for rows.Next() {
+		var eventID int
+		var count int64
+		if err := rows.Scan(&eventID, &count); err != nil {
+			return 0, err
+		}
+		affected[eventID] = true
+		total += count
+	}
+	return total, rows.Err()
+}
+
+// GetAvailableSeats returns the seats of an event that can still be booked:
+// those not taken by confirmed bookings, pending bookings within their hold
+// or reservations.
+func (s *Storage) GetAvailableSeats(ctx context.Context, eventID int) (int64, error) {
+	const op = "storage.GetAvailableSeats"
+
+	log.Printf("%s: Calculating available seats for event ID: %d", op, eventID)
+
+	query := `
+        SELECT e.total_seats::bigint - COALESCE((
+            SELECT SUM(c.seats) FROM bookings c WHERE c.event_id = e.id AND c.status = 'confirmed'
+        ), 0) - ` + heldPendingSeats + ` - ` + reservedSeats + `
+        FROM events e
+        WHERE e.id = $1
+    `
+
+	return s.availableSeats(ctx, op, query, eventID)
+}
+
+// GetConfirmedAvailableSeats returns the seats of an event not taken by
+// confirmed bookings, ignoring pending holds and reservations.
+func (s *Storage) GetConfirmedAvailableSeats(ctx context.Context, eventID int) (int64, error) {
+	const op = "storage.GetConfirmedAvailableSeats"
+
+	log.Printf("%s: Calculating seats left after confirmed bookings for event ID: %d", op, eventID)
+
+	query := `
+        SELECT e.total_seats::bigint - COALESCE(SUM(b.seats), 0)
+        FROM events e
+        LEFT JOIN bookings b ON e.id = b.event_id AND b.status = 'confirmed'
+        WHERE e.id = $1
+        GROUP BY e.id, e.total_seats
+    `
+
+	return s.availableSeats(ctx, op, query, eventID)
+}
+
+func (s *Storage) availableSeats(ctx context.Context, op, query string, eventID int) (int64, error) {
+	var available int64
+	err := s.retryRead(ctx, op, func() error {
+		return s.pool.QueryRow(ctx, query, eventID).Scan(&available)
+	})
+	if err != nil {
+		log.Printf("%s: Failed to calculate available seats for event %d: %v", op, eventID, err)
+		return 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	available = clampAvailable(op, eventID, available)
+
+	log.Printf("%s: Event ID %d has %d available seats", op, eventID, available)
+	return available, nil
+}
+
+// clampAvailable reports an over-confirmed event, e.g. one whose total_seats
+// was lowered by hand, as sold out rather than with negative availability.
+func clampAvailable(op string, eventID int, available int64) int64 {
+	if available < 0 {
+		log.Printf("%s: Event %d is over-confirmed by %d seats, reporting 0 available", op, eventID, -available)
+		return 0
+	}
+	return available
+}
+
+// Bucket sizes accepted by GetUtilization; they are date_trunc field names.
+const (
+	UtilizationBucketHour = "hour"
+	UtilizationBucketDay  = "day"
+	UtilizationBucketWeek = "week"
+)
+
+// GetUtilization returns an event's confirmed seats per bucket, from the
+// first confirmation's bucket to the last one's. Buckets without
+// confirmations are included with zero seats so charts have no gaps.
+func (s *Storage) GetUtilization(ctx context.Context, eventID int, bucket string) ([]models.UtilizationPoint, error) {
+	const op = "storage.GetUtilization"
+
+	log.Printf("%s: Calculating %s utilization for event ID: %d", op, bucket, eventID)
+
+	query := `
+        WITH per_bucket AS (
+            SELECT date_trunc($2, confirmed_at) AS bucket, SUM(seats)::bigint AS seats
+            FROM bookings
+            WHERE event_id = $1 AND status = 'confirmed' AND confirmed_at IS NOT NULL
+            GROUP BY 1
+        )
+        SELECT g.bucket, COALESCE(p.seats, 0), 
+               (SUM(COALESCE(p.seats, 0)) OVER (ORDER BY g.bucket))::bigint
+        FROM generate_series((SELECT MIN(bucket) FROM per_bucket), 
+                             (SELECT MAX(bucket) FROM per_bucket), 
+                             ('1 ' || $2)::interval) AS g(bucket)
+        LEFT JOIN per_bucket p ON p.bucket = g.bucket
+        ORDER BY g.bucket
+    `
+
+	rows, err := s.queryRead(ctx, op, query, eventID, bucket)
+	if err != nil {
+		log.Printf("%s: Failed to query utilization for event %d: %v", op, eventID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	points := []models.UtilizationPoint{}
+	for rows.Next() {
+		var p models.UtilizationPoint
+		if err := rows.Scan(&p.Bucket, &p.Seats, &p.Cumulative); err != nil {
+			log.Printf("%s: Failed to scan utilization row: %v", op, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate utilization rows: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Event ID %d has %d utilization buckets", op, eventID, len(points))
+	return points, nil
+}
+
+// GetSeatTypeAvailability lists an event's seat types with their remaining
+// seats, less those held by pending bookings as for a new booking. Events
+// without seat types yield an empty list.
+func (s *Storage) GetSeatTypeAvailability(ctx context.Context, eventID int) ([]models.SeatTypeAvailability, error) {
+	const op = "storage.GetSeatTypeAvailability"
+
+	log.Printf("%s: Calculating available seats per type for event ID: %d", op, eventID)
+
+	query := `
+        SELECT st.type, st.total, st.price, st.total::bigint - ` + seatTypeTaken + `
+        FROM seat_types st
+        JOIN events e ON e.id = st.event_id
+        WHERE st.event_id = $1
+        ORDER BY st.type
+    `
+
+	rows, err := s.queryRead(ctx, op, query, eventID)
+	if err != nil {
+		log.Printf("%s: Failed to query seat types for event %d: %v", op, eventID, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	types := []models.SeatTypeAvailability{}
+	for rows.Next() {
+		var t models.SeatTypeAvailability
+		if err := rows.Scan(&t.Type, &t.Total, &t.Price, &t.Available); err != nil {
+			log.Printf("%s: Failed to scan seat type row: %v", op, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+		t.Available = clampAvailable(op, eventID, t.Available)
+		types = append(types, t)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate seat type rows: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Event ID %d has %d seat types", op, eventID, len(types))
+	return types, nil
+}
+
+func (s *Storage) GetSeatCounts(ctx context.Context, eventIDs []int) (map[int]models.SeatCounts, error) {
+	const op = "storage.GetSeatCounts"
+
+	log.Printf("%s: Counting seats for %d events", op, len(eventIDs))
+
+	query := `
+        SELECT e.id,
+               e.total_seats::bigint - COALESCE(SUM(b.seats) FILTER (WHERE b.status = 'confirmed'), 0)
+                   - ` + heldPendingSeats + ` - ` + reservedSeats + `,
+               COALESCE(SUM(b.seats) FILTER (WHERE b.status = 'confirmed'), 0),
+               COALESCE(SUM(b.seats) FILTER (WHERE b.status = 'pending'), 0),
+               ` + reservedSeats + `
+        FROM events e
+        LEFT JOIN bookings b ON e.id = b.event_id
+        WHERE e.id = ANY($1)
+        GROUP BY e.id, e.total_seats
+    `
+
+	rows, err := s.queryRead(ctx, op, query, eventIDs)
+	if err != nil {
+		log.Printf("%s: Failed to query seat counts: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]models.SeatCounts, len(eventIDs))
+	for rows.Next() {
+		var id int
+		var c models.SeatCounts
+		if err := rows.Scan(&id, &c.Available, &c.Confirmed, &c.Pending, &c.Reserved); err != nil {
+			log.Printf("%s: Failed to scan seat counts row: %v", op, err)
+			return nil, fmt.Errorf("%s: %v", op, err)
+		}
+		c.Available = clampAvailable(op, id, c.Available)
+		counts[id] = c
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate seat counts rows: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Counted seats for %d events", op, len(counts))
+	return counts, nil
+}
+
+func (s *Storage) GetAllEvents(ctx context.Context, filter models.EventFilter) ([]models.Event, error) {
+	const op = "storage.GetAllEvents"
+
+	log.Printf("%s: Retrieving all events, filter: %+v", op, filter)
+
+	conds, args := eventFilterConds(filter, nil)
+	order, args := eventOrder(filter, args)
+	query := `SELECT ` + eventColumns + ` FROM events` + whereClause(conds) + ` ORDER BY ` + order
+
+	rows, err := s.queryRead(ctx, op, query, args...)
+	if err != nil {
+		log.Printf("%s: Failed to query all events: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
 
 	var events []models.Event
 	for rows.Next() {
 		var event models.Event
-		err := rows.Scan(
-			&event.ID,
-			&event.Name,
-			&event.Date,
-			&event.TotalSeats,
-			&event.PaymentTime,
-			&event.CreatedAt,
-		)
+		err := scanEvent(rows, &event)
 		if err != nil {
 			log.Printf("%s: Failed to scan event row: %v", op, err)
 			return nil, fmt.Errorf("%s: %v", op, err)
@@ -267,3 +2724,478 @@ func (s *Storage) GetAllEvents(ctx context.Context) ([]models.Event, error) {
 	log.Printf("%s: Retrieved %d events", op, len(events))
 	return events, nil
 }
+
+// GetAllEventsPaginated returns one page of the events matching filter, in
+// the order GetAllEvents uses, with the number of matching events.
+func (s *Storage) GetAllEventsPaginated(ctx context.Context, filter models.EventFilter, limit, offset int) ([]models.Event, int, error) {
+	const op = "storage.GetAllEventsPaginated"
+
+	log.Printf("%s: Retrieving events - Limit: %d, Offset: %d, filter: %+v", op, limit, offset, filter)
+
+	conds, args := eventFilterConds(filter, nil)
+	where := whereClause(conds)
+
+	// One snapshot for both, so the total always describes the page
+	var tx pgx.Tx
+	err := s.retryRead(ctx, op, func() error {
+		var err error
+		tx, err = s.beginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+		return err
+	})
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var total int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM events`+where, args...).Scan(&total); err != nil {
+		log.Printf("%s: Failed to count events: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	order, args := eventOrder(filter, args)
+	args = append(args, limit, offset)
+	query := `SELECT ` + eventColumns + ` FROM events` + where + ` ORDER BY ` + order +
+		fmt.Sprintf(` LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("%s: Failed to query events: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	events := []models.Event{}
+	for rows.Next() {
+		var event models.Event
+		if err := scanEvent(rows, &event); err != nil {
+			log.Printf("%s: Failed to scan event row: %v", op, err)
+			return nil, 0, fmt.Errorf("%s: %v", op, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate event rows: %v", op, err)
+		return nil, 0, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Retrieved %d of %d events", op, len(events), total)
+	return events, total, nil
+}
+
+// ExportEvents streams every event with its seat types to fn, ordered by ID,
+// without holding them all in memory. With includeBookings each event also
+// carries its bookings. Iteration stops at the first error fn returns.
+func (s *Storage) ExportEvents(ctx context.Context, includeBookings bool, fn func(models.EventExport) error) error {
+	const op = "storage.ExportEvents"
+
+	log.Printf("%s: Exporting events, include bookings: %t", op, includeBookings)
+
+	query := `SELECT ` + eventColumns + `,
+                  (SELECT COALESCE(json_agg(json_build_object('type', type, 'total', total, 'price', price) ORDER BY type), '[]')
+                   FROM seat_types WHERE event_id = events.id)
+              FROM events ORDER BY id ASC`
+
+	rows, err := s.queryRead(ctx, op, query)
+	if err != nil {
+		log.Printf("%s: Failed to query events: %v", op, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var export models.EventExport
+		if err := scanEvent(rows, &export.Event, &export.SeatTypes); err != nil {
+			log.Printf("%s: Failed to scan event row: %v", op, err)
+			return fmt.Errorf("%s: %v", op, err)
+		}
+
+		if includeBookings {
+			// Runs on another pooled connection while this cursor stays open
+			export.Bookings, err = s.GetEventBookings(ctx, export.ID)
+			if err != nil {
+				return fmt.Errorf("%s: %w", op, err)
+			}
+		}
+
+		if err := fn(export); err != nil {
+			log.Printf("%s: Export interrupted after %d events: %v", op, count, err)
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate event rows: %v", op, err)
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Printf("%s: Exported %d events", op, count)
+	return nil
+}
+
+// Import modes accepted by ImportEvents for events whose ID already exists.
+const (
+	ImportModeSkip   = "skip"
+	ImportModeUpsert = "upsert"
+)
+
+// ImportEvents restores exported events, keeping their IDs, in a single
+// transaction. Existing IDs are left alone in skip mode and overwritten,
+// seat types and bookings included, in upsert mode. Imported bookings have no
+// confirm token, so pending ones can only expire or be confirmed by an admin.
+func (s *Storage) ImportEvents(ctx context.Context, events []models.EventExport, mode string) (models.ImportResult, error) {
+	const op = "storage.ImportEvents"
+
+	log.Printf("%s: Importing %d events, mode: %s", op, len(events), mode)
+
+	var result models.ImportResult
+
+	conflict := `DO NOTHING`
+	if mode == ImportModeUpsert {
+		conflict = `DO UPDATE SET name = EXCLUDED.name, date = EXCLUDED.date, total_seats = EXCLUDED.total_seats,
+                    payment_time = EXCLUDED.payment_time, grace_minutes = EXCLUDED.grace_minutes,
+                    organizer_id = EXCLUDED.organizer_id, timezone = EXCLUDED.timezone, 
+                    hide_exact_below = EXCLUDED.hide_exact_below, one_booking_per_user = EXCLUDED.one_booking_per_user, 
+                    cancellation_deadline_hours = EXCLUDED.cancellation_deadline_hours, metadata = EXCLUDED.metadata,
+                    created_at = EXCLUDED.created_at`
+	}
+	// xmax is zero only for freshly inserted rows, which tells inserts from updates
+	eventQuery := `INSERT INTO events (id, name, date, total_seats, payment_time, grace_minutes, organizer_id, timezone, 
+                                      hide_exact_below, one_booking_per_user, cancellation_deadline_hours, metadata, created_at)
+                   VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $12, $13)
+                   ON CONFLICT (id) ` + conflict + ` RETURNING xmax = 0`
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		log.Printf("%s: Failed to begin transaction: %v", op, err)
+		return models.ImportResult{}, fmt.Errorf("%s: %v", op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, event := range events {
+		// Restored bookings replace the current ones; without any, the
+		// current confirmed bookings are kept and must still fit
+		var confirmed int64
+		if event.Bookings != nil {
+			for _, b := range event.Bookings {
+				if b.Status == models.BookingConfirmed {
+					confirmed += int64(b.Seats)
+				}
+			}
+		} else if mode == ImportModeUpsert {
+			err := tx.QueryRow(ctx, `SELECT confirmed_seats FROM events WHERE id = $1 FOR UPDATE`, event.ID).Scan(&confirmed)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				log.Printf("%s: Failed to get confirmed seats of event %d: %v", op, event.ID, err)
+				return models.ImportResult{}, fmt.Errorf("%s: %v", op, err)
+			}
+		}
+		if confirmed > int64(event.TotalSeats) {
+			log.Printf("%s: Event %d has %d confirmed seats but only %d in total", op, event.ID, confirmed, event.TotalSeats)
+			return models.ImportResult{}, fmt.Errorf("%s: event %d: %w", op, event.ID, ErrSeatsBelowTaken)
+		}
+
+		var inserted bool
+		err := tx.QueryRow(ctx, eventQuery,
+			event.ID,
+			event.Name,
+			event.Date.UTC(),
+			event.TotalSeats,
+			event.PaymentTime,
+			event.GraceMinutes,
+			event.OrganizerID,
+			event.Timezone,
+			event.HideExactBelow,
+			event.OneBookingPerUser,
+			event.CancellationDeadlineHours,
+			event.Metadata,
+			event.CreatedAt.UTC()).Scan(&inserted)
+		if errors.Is(err, pgx.ErrNoRows) {
+			result.Skipped++
+			continue
+		}
+		if err != nil {
+			log.Printf("%s: Failed to import event %d: %v", op, event.ID, err)
+			return models.ImportResult{}, fmt.Errorf("%s: event %d: %w", op, event.ID, translateConstraint(err))
+		}
+
+		if !inserted {
+			// Replace rather than merge so the event matches the backup exactly
+			if _, err := tx.Exec(ctx, `DELETE FROM seat_types WHERE event_id = $1`, event.ID); err != nil {
+				log.Printf("%s: Failed to clear seat types of event %d: %v", op, event.ID, err)
+				return models.ImportResult{}, fmt.Errorf("%s: %v", op, err)
+			}
+			if event.Bookings != nil {
+				if _, err := tx.Exec(ctx, `DELETE FROM bookings WHERE event_id = $1`, event.ID); err != nil {
+					log.Printf("%s: Failed to clear bookings of event %d: %v", op, event.ID, err)
+					return models.ImportResult{}, fmt.Errorf("%s: %v", op, err)
+				}
+			}
+		}
+
+		for _, st := range event.SeatTypes {
+			_, err = tx.Exec(ctx, `INSERT INTO seat_types (event_id, type, total, price) VALUES ($1, $2, $3, $4)`,
+				event.ID, st.Type, st.Total, st.Price)
+			if err != nil {
+				log.Printf("%s: Failed to import seat type %q of event %d: %v", op, st.Type, event.ID, err)
+				return models.ImportResult{}, fmt.Errorf("%s: event %d: %w", op, event.ID, translateConstraint(err))
+			}
+		}
+
+		for _, b := range event.Bookings {
+			_, err = tx.Exec(ctx, `INSERT INTO bookings (id, event_id, user_name, seats, status, seat_type, reference, created_at, confirmed_at, checked_in_at, cancel_reason, one_per_user)
+                    VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''),
+                            COALESCE(NULLIF($7, ''), upper(substr(md5(random()::text || clock_timestamp()::text), 1, 16))), $8, $9, $10, NULLIF($11, ''), $12)`,
+				b.ID, event.ID, b.UserName, b.Seats, b.Status, b.SeatType, b.Reference, b.CreatedAt.UTC(), b.ConfirmedAt, b.CheckedInAt, b.CancelReason, event.OneBookingPerUser)
+			if err != nil {
+				log.Printf("%s: Failed to import booking %d of event %d: %v", op, b.ID, event.ID, err)
+				return models.ImportResult{}, fmt.Errorf("%s: booking %d: %w", op, b.ID, translateConstraint(err))
+			}
+		}
+
+		// Bookings kept from before the import follow the event's current flag
+		_, err = tx.Exec(ctx, `UPDATE bookings SET one_per_user = $2 WHERE event_id = $1 AND one_per_user <> $2`,
+			event.ID, event.OneBookingPerUser)
+		if err != nil {
+			log.Printf("%s: Failed to apply one_booking_per_user to bookings of event %d: %v", op, event.ID, err)
+			return models.ImportResult{}, fmt.Errorf("%s: event %d: %w", op, event.ID, translateConstraint(err))
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE events SET confirmed_seats = COALESCE((
+                SELECT SUM(seats) FROM bookings WHERE event_id = $1 AND status = 'confirmed'
+            ), 0) WHERE id = $1`, event.ID)
+		if err != nil {
+			log.Printf("%s: Failed to recount confirmed seats of event %d: %v", op, event.ID, err)
+			return models.ImportResult{}, fmt.Errorf("%s: %v", op, err)
+		}
+
+		if inserted {
+			result.Inserted++
+		} else {
+			result.Updated++
+		}
+	}
+
+	// Explicit IDs bypass the sequences, so move them past the imported rows
+	for _, table := range []string{"events", "bookings"} {
+		_, err := tx.Exec(ctx, `SELECT setval(pg_get_serial_sequence('`+table+`', 'id'),
+                GREATEST((SELECT MAX(id) FROM `+table+`), 1))`)
+		if err != nil {
+			log.Printf("%s: Failed to advance %s sequence: %v", op, table, err)
+			return models.ImportResult{}, fmt.Errorf("%s: %v", op, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("%s: Failed to commit import: %v", op, err)
+		return models.ImportResult{}, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Imported events - inserted: %d, updated: %d, skipped: %d", op, result.Inserted, result.Updated, result.Skipped)
+	return result, nil
+}
+
+func (s *Storage) GetEventsByOrganizer(ctx context.Context, organizerID int, filter models.EventFilter) ([]models.Event, error) {
+	filter.OrganizerID = &organizerID
+	return s.GetAllEvents(ctx, filter)
+}
+
+func (s *Storage) GetEventsAfterCursor(ctx context.Context, filter models.EventFilter, after *models.EventCursor, limit int) ([]models.Event, *models.EventCursor, error) {
+	const op = "storage.GetEventsAfterCursor"
+
+	log.Printf("%s: Retrieving up to %d events after cursor %+v, filter: %+v", op, limit, after, filter)
+
+	conds, args := eventFilterConds(filter, nil)
+	if after != nil {
+		args = append(args, after.Date.UTC(), after.ID)
+		conds = append(conds, fmt.Sprintf("(date, id) > ($%d, $%d)", len(args)-1, len(args)))
+	}
+
+	// Fetch one extra row to learn whether another page follows
+	args = append(args, limit+1)
+	query := `SELECT ` + eventColumns + ` FROM events` + whereClause(conds) +
+		fmt.Sprintf(` ORDER BY date ASC, id ASC LIMIT $%d`, len(args))
+
+	rows, err := s.queryRead(ctx, op, query, args...)
+	if err != nil {
+		log.Printf("%s: Failed to query events: %v", op, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
+	defer rows.Close()
+
+	events := []models.Event{}
+	for rows.Next() {
+		var event models.Event
+		err := scanEvent(rows, &event)
+		if err != nil {
+			log.Printf("%s: Failed to scan event row: %v", op, err)
+			return nil, nil, fmt.Errorf("%s: %v", op, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("%s: Failed to iterate event rows: %v", op, err)
+		return nil, nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	var next *models.EventCursor
+	if len(events) > limit {
+		events = events[:limit]
+		last := events[len(events)-1]
+		next = &models.EventCursor{Date: last.Date, ID: last.ID}
+	}
+
+	log.Printf("%s: Retrieved %d events, has next page: %t", op, len(events), next != nil)
+	return events, next, nil
+}
+
+// GetNextEvent returns the soonest upcoming event that still has seats
+// available, or ErrEventNotFound when there is none.
+func (s *Storage) GetNextEvent(ctx context.Context) (*models.Event, error) {
+	const op = "storage.GetNextEvent"
+
+	log.Printf("%s: Retrieving next upcoming event with available seats", op)
+
+	query := `SELECT ` + eventColumns + ` FROM events e
+              WHERE e.date >= (NOW() AT TIME ZONE 'UTC')
+              AND e.total_seats::bigint - COALESCE((
+                  SELECT SUM(c.seats) FROM bookings c WHERE c.event_id = e.id AND c.status = 'confirmed'
+              ), 0) - ` + heldPendingSeats + ` - ` + reservedSeats + ` > 0
+              ORDER BY e.date ASC, e.id ASC
+              LIMIT 1`
+
+	var event models.Event
+	err := s.retryRead(ctx, op, func() error {
+		return scanEvent(s.pool.QueryRow(ctx, query), &event)
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: No upcoming event with available seats", op)
+		return nil, fmt.Errorf("%s: %w", op, ErrEventNotFound)
+	}
+	if err != nil {
+		log.Printf("%s: Failed to retrieve next event: %v", op, err)
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Next event is ID %d: %s", op, event.ID, event.Name)
+	return &event, nil
+}
+
+// eventFilterConds renders filter as SQL conditions whose placeholders are
+// numbered after the arguments already in args.
+func eventFilterConds(filter models.EventFilter, args []any) ([]string, []any) {
+	var conds []string
+	if !filter.IncludePast {
+		// Event dates are stored as UTC wall-clock time
+		conds = append(conds, "date >= (NOW() AT TIME ZONE 'UTC')")
+	}
+	if filter.OrganizerID != nil {
+		args = append(args, *filter.OrganizerID)
+		conds = append(conds, fmt.Sprintf("organizer_id = $%d", len(args)))
+	}
+	if filter.SeriesID != nil {
+		args = append(args, *filter.SeriesID)
+		conds = append(conds, fmt.Sprintf("series_id = $%d", len(args)))
+	}
+	switch from, to := filter.CreatedFrom, filter.CreatedTo; {
+	case !from.IsZero() && !to.IsZero():
+		args = append(args, from.UTC(), to.UTC())
+		conds = append(conds, fmt.Sprintf("created_at BETWEEN $%d AND $%d", len(args)-1, len(args)))
+	case !from.IsZero():
+		args = append(args, from.UTC())
+		conds = append(conds, fmt.Sprintf("created_at >= $%d", len(args)))
+	case !to.IsZero():
+		args = append(args, to.UTC())
+		conds = append(conds, fmt.Sprintf("created_at <= $%d", len(args)))
+	}
+	for _, key := range slices.Sorted(maps.Keys(filter.Metadata)) {
+		args = append(args, key, filter.Metadata[key])
+		conds = append(conds, fmt.Sprintf("metadata ->> $%d = $%d", len(args)-1, len(args)))
+	}
+	if filter.Query != "" {
+		// Substring matches catch partial words and names made only of stop
+		// words, whose search_vector is empty
+		args = append(args, filter.Query, "%"+likeEscaper.Replace(filter.Query)+"%")
+		conds = append(conds, fmt.Sprintf("(search_vector @@ %s OR name ILIKE $%d)", searchTSQuery(len(args)-1), len(args)))
+	}
+	return conds, args
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// searchTSQuery is the tsquery for the search text in placeholder n. Its
+// words are ORed, so events matching more of them rank higher rather than
+// every word being required.
+func searchTSQuery(n int) string {
+	return fmt.Sprintf(`replace(plainto_tsquery('english', $%d)::text, ' & ', ' | ')::tsquery`, n)
+}
+
+// eventOrder is the ORDER BY list for events matching filter: by relevance
+// to its search text when there is one, by date otherwise.
+func eventOrder(filter models.EventFilter, args []any) (string, []any) {
+	if filter.Query == "" {
+		return "date ASC, id ASC", args
+	}
+	args = append(args, filter.Query)
+	return fmt.Sprintf("ts_rank(search_vector, %s) DESC, date ASC, id ASC", searchTSQuery(len(args))), args
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// BeginWebhookDelivery records a delivery attempt for a booking notification.
+// It reports delivered=true, without counting an attempt, when the
+// notification already went out so a redelivery can be suppressed.
+func (s *Storage) BeginWebhookDelivery(ctx context.Context, bookingID, eventID int) (attempt int, delivered bool, err error) {
+	const op = "storage.BeginWebhookDelivery"
+
+	query := `INSERT INTO webhook_deliveries (booking_id, event_id) 
+              VALUES ($1, $2)
+              ON CONFLICT (booking_id, event_id) DO UPDATE 
+              SET attempts = webhook_deliveries.attempts + 1
+              WHERE webhook_deliveries.delivered_at IS NULL
+              RETURNING attempts`
+
+	err = s.pool.QueryRow(ctx, query, bookingID, eventID).Scan(&attempt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		log.Printf("%s: Webhook for booking %d, event %d already delivered, suppressing", op, bookingID, eventID)
+		return 0, true, nil
+	}
+	if err != nil {
+		log.Printf("%s: Failed to record delivery attempt for booking %d: %v", op, bookingID, err)
+		return 0, false, fmt.Errorf("%s: %v", op, err)
+	}
+
+	log.Printf("%s: Delivery attempt %d for booking %d, event %d", op, attempt, bookingID, eventID)
+	return attempt, false, nil
+}
+
+func (s *Storage) MarkWebhookDelivered(ctx context.Context, bookingID, eventID int) error {
+	const op = "storage.MarkWebhookDelivered"
+
+	query := `UPDATE webhook_deliveries SET delivered_at = NOW() 
+              WHERE booking_id = $1 AND event_id = $2 AND delivered_at IS NULL`
+
+	// Marking twice is harmless, so this write may be retried
+	var res pgconn.CommandTag
+	err := s.retryRead(ctx, op, func() error {
+		var err error
+		res, err = s.pool.Exec(ctx, query, bookingID, eventID)
+		return err
+	})
+	if err != nil {
+		log.Printf("%s: Failed to mark delivery for booking %d: %v", op, bookingID, err)
+		return fmt.Errorf("%s: %v", op, err)
+	}
+	if res.RowsAffected() == 0 {
+		log.Printf("%s: No undelivered attempt for booking %d, event %d", op, bookingID, eventID)
+	}
+
+	log.Printf("%s: Marked webhook delivered for booking %d, event %d", op, bookingID, eventID)
+	return nil
+}
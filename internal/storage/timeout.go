@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type statementTimeoutKey struct{}
+
+// WithStatementTimeout returns a context whose transactions run with the
+// given statement_timeout, for debugging slow queries. Timeouts below a
+// millisecond are raised to one, since zero would disable the limit.
+func WithStatementTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, statementTimeoutKey{}, max(timeout, time.Millisecond))
+}
+
+// begin starts a transaction, applying the statement timeout carried by ctx
+// to it alone.
+func (s *Storage) begin(ctx context.Context) (pgx.Tx, error) {
+	return s.beginTx(ctx, pgx.TxOptions{})
+}
+
+// beginTx is begin with the given transaction options.
+func (s *Storage) beginTx(ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	tx, err := s.pool.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, ok := ctx.Value(statementTimeoutKey{}).(time.Duration)
+	if !ok {
+		return tx, nil
+	}
+	// SET takes no parameters, but the value is a formatted integer
+	if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout.Milliseconds())); err != nil {
+		tx.Rollback(ctx)
+		return nil, err
+	}
+	log.Printf("storage.begin: Transaction statement timeout set to %s", timeout)
+	return tx, nil
+}
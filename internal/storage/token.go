@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// newConfirmToken returns a random confirmation token and the hash stored in
+// its place. Only the booker ever sees the plain token.
+func newConfirmToken() (token, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, hashConfirmToken(token), nil
+}
+
+func hashConfirmToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+var ErrVersionMismatch = errors.New("booking was changed since it was read")
+
+type bookingVersionKey struct{}
+
+type bookingVersion struct {
+	bookingID int
+	version   int
+}
+
+// WithBookingVersion returns a context under which changes to a booking
+// only go ahead while it is still the given booking at the given version,
+// failing with ErrVersionMismatch otherwise.
+func WithBookingVersion(ctx context.Context, bookingID, version int) context.Context {
+	return context.WithValue(ctx, bookingVersionKey{}, bookingVersion{bookingID: bookingID, version: version})
+}
+
+// checkBookingVersion compares a booking locked for change with the version
+// expected by ctx, if any.
+func checkBookingVersion(ctx context.Context, op string, bookingID, version int) error {
+	expected, ok := ctx.Value(bookingVersionKey{}).(bookingVersion)
+	if !ok || expected == (bookingVersion{bookingID: bookingID, version: version}) {
+		return nil
+	}
+	log.Printf("%s: Booking %d is at version %d, expected booking %d at version %d",
+		op, bookingID, version, expected.bookingID, expected.version)
+	return fmt.Errorf("%s: %w", op, ErrVersionMismatch)
+}
+
+func expectsBookingVersion(ctx context.Context) bool {
+	_, ok := ctx.Value(bookingVersionKey{}).(bookingVersion)
+	return ok
+}
@@ -0,0 +1,8 @@
+CREATE TABLE webhook_deliveries (
+    booking_id INTEGER NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    delivered_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (booking_id, event_id)
+);
\ No newline at end of file
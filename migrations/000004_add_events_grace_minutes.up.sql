@@ -0,0 +1 @@
+ALTER TABLE events ADD COLUMN grace_minutes INTEGER;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE events ADD COLUMN organizer_id INTEGER;
+
+CREATE INDEX idx_events_organizer_id ON events(organizer_id);
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE bookings ADD COLUMN confirm_token_hash TEXT;
\ No newline at end of file
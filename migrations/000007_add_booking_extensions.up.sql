@@ -0,0 +1,12 @@
+ALTER TABLE bookings ADD COLUMN extension_minutes INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE booking_extensions (
+    id SERIAL PRIMARY KEY,
+    booking_id INTEGER NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    organizer_id INTEGER NOT NULL,
+    extra_minutes INTEGER NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_booking_extensions_booking_id ON booking_extensions(booking_id);
\ No newline at end of file
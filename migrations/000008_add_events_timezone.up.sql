@@ -0,0 +1 @@
+ALTER TABLE events ADD COLUMN timezone TEXT;
\ No newline at end of file
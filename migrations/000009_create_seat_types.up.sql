@@ -0,0 +1,9 @@
+CREATE TABLE seat_types (
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    total INTEGER NOT NULL CHECK (total > 0),
+    price INTEGER NOT NULL DEFAULT 0 CHECK (price >= 0),
+    PRIMARY KEY (event_id, type)
+);
+
+ALTER TABLE bookings ADD COLUMN seat_type TEXT;
\ No newline at end of file
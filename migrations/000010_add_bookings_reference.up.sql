@@ -0,0 +1,2 @@
+ALTER TABLE bookings ADD COLUMN reference TEXT NOT NULL UNIQUE
+    DEFAULT upper(substr(md5(random()::text || clock_timestamp()::text), 1, 16));
\ No newline at end of file
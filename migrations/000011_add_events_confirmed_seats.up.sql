@@ -0,0 +1,6 @@
+ALTER TABLE events ADD COLUMN confirmed_seats INTEGER NOT NULL DEFAULT 0;
+
+UPDATE events e SET confirmed_seats = COALESCE((
+    SELECT SUM(b.seats) FROM bookings b
+    WHERE b.event_id = e.id AND b.status = 'confirmed'
+), 0);
\ No newline at end of file
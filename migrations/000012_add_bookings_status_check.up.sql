@@ -0,0 +1,2 @@
+ALTER TABLE bookings ADD CONSTRAINT bookings_status_check
+    CHECK (status IN ('pending', 'confirmed', 'cancelled'));
\ No newline at end of file
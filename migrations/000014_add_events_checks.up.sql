@@ -0,0 +1,5 @@
+ALTER TABLE events
+    ADD CONSTRAINT events_total_seats_check CHECK (total_seats > 0),
+    ADD CONSTRAINT events_payment_time_check CHECK (payment_time > 0),
+    ADD CONSTRAINT events_grace_minutes_check CHECK (grace_minutes >= 0),
+    ADD CONSTRAINT events_confirmed_seats_check CHECK (confirmed_seats >= 0);
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE bookings ADD COLUMN cancel_reason TEXT;
+
+ALTER TABLE bookings ADD CONSTRAINT bookings_cancel_reason_check
+    CHECK (cancel_reason IN ('user_request', 'refunded', 'expired', 'released'));
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE bookings ADD COLUMN confirmed_at TIMESTAMP;
+
+UPDATE bookings SET confirmed_at = created_at WHERE status = 'confirmed';
\ No newline at end of file
@@ -0,0 +1,10 @@
+-- Events created before the constraint may repeat an organizer's name and date:
+-- keep the oldest of each as is and tell the others apart by their ID
+UPDATE events e SET name = e.name || ' (#' || e.id || ')'
+FROM (
+    SELECT id, row_number() OVER (PARTITION BY organizer_id, name, date ORDER BY id) AS n
+    FROM events
+) ranked
+WHERE ranked.id = e.id AND ranked.n > 1;
+
+ALTER TABLE events ADD CONSTRAINT events_organizer_name_date_key UNIQUE NULLS NOT DISTINCT (organizer_id, name, date);
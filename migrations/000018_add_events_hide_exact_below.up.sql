@@ -0,0 +1,3 @@
+ALTER TABLE events ADD COLUMN hide_exact_below INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE events ADD CONSTRAINT events_hide_exact_below_check CHECK (hide_exact_below >= 0);
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE events ADD COLUMN one_booking_per_user BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE bookings ADD COLUMN one_per_user BOOLEAN NOT NULL DEFAULT false;
+
+CREATE UNIQUE INDEX bookings_one_per_user_key ON bookings (event_id, user_name)
+    WHERE one_per_user AND status <> 'cancelled';
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE bookings ADD COLUMN expires_at TIMESTAMP;
+
+UPDATE bookings b SET expires_at = b.created_at + ((e.payment_time + COALESCE(e.grace_minutes, 0)) * interval '1 minute')
+FROM events e WHERE e.id = b.event_id;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE events ADD COLUMN cancellation_deadline_hours INTEGER;
+
+ALTER TABLE events ADD CONSTRAINT events_cancellation_deadline_hours_check CHECK (cancellation_deadline_hours >= 0);
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE TABLE waitlist_entries (
+    id SERIAL PRIMARY KEY,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    user_name TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX waitlist_entries_pending_key ON waitlist_entries (event_id, user_name) WHERE status = 'pending';
\ No newline at end of file
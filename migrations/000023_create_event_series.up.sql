@@ -0,0 +1,8 @@
+CREATE TABLE event_series (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE events ADD COLUMN series_id INTEGER REFERENCES event_series(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_events_series_id ON events(series_id);
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE bookings DROP CONSTRAINT bookings_cancel_reason_check;
+
+ALTER TABLE bookings ADD CONSTRAINT bookings_cancel_reason_check
+    CHECK (cancel_reason IN ('user_request', 'refunded', 'expired', 'released', 'moved'));
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE reservations (
+    id SERIAL PRIMARY KEY,
+    event_id INTEGER NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    user_name TEXT NOT NULL,
+    seats INTEGER NOT NULL CHECK (seats > 0),
+    token_hash TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_reservations_event_id ON reservations(event_id);
+CREATE INDEX idx_reservations_expires_at ON reservations(expires_at);
\ No newline at end of file
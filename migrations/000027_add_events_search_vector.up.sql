@@ -0,0 +1,2 @@
+ALTER TABLE events ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (to_tsvector('english', name)) STORED;
+CREATE INDEX idx_events_search_vector ON events USING GIN (search_vector);
\ No newline at end of file
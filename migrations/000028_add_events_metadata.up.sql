@@ -0,0 +1,3 @@
+ALTER TABLE events ADD COLUMN metadata JSONB;
+
+ALTER TABLE events ADD CONSTRAINT events_metadata_check CHECK (jsonb_typeof(metadata) = 'object');
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE bookings ADD COLUMN heartbeat_until TIMESTAMP;
\ No newline at end of file
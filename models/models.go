@@ -1,24 +1,213 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Defaults filled in by ApplyDefaults for settings left unset.
+const (
+	DefaultServerPort          = "8080"
+	DefaultJSONCase            = "snake"
+	DefaultPageSize            = 20
+	DefaultMaxPageSize         = 100
+	DefaultLongPollTimeout     = 30 * time.Second
+	DefaultMinPaymentTime      = 1
+	DefaultMaxTotalSeats       = 1_000_000
+	DefaultDuplicateWindow     = time.Hour
+	DefaultWebhookTimeout      = 5 * time.Second
+	DefaultWorkerInterval      = time.Minute
+	DefaultReservationTTL      = 5 * time.Minute
+	DefaultReservationInterval = 15 * time.Second
+	DefaultLogLevel            = "info"
+)
+
 type Config struct {
-	Server struct {
-		Port string `yaml:"port"`
-	} `yaml:"server"`
-	Database struct {
-		Host     string `yaml:"host"`
-		Port     string `yaml:"port"`
-		User     string `yaml:"user"`
-		Password string `yaml:"password"`
-		Name     string `yaml:"name"`
-	} `yaml:"database"`
+	Server     ServerConfig     `yaml:"server" json:"server"`
+	API        APIConfig        `yaml:"api" json:"api"`
+	Database   DatabaseConfig   `yaml:"database" json:"database"`
+	Events     EventsConfig     `yaml:"events" json:"events"`
+	Admin      AdminConfig      `yaml:"admin" json:"admin"`
+	Organizers OrganizersConfig `yaml:"organizers" json:"organizers"`
+	Checkin    CheckinConfig    `yaml:"checkin" json:"checkin"`
+	Webhook    WebhookConfig    `yaml:"webhook" json:"webhook"`
+	Worker     WorkerConfig     `yaml:"worker" json:"worker"`
+	Logging    LoggingConfig    `yaml:"logging" json:"logging"`
+}
+
+type ServerConfig struct {
+	Port            string `yaml:"port" json:"port"`
+	EnableProfiling bool   `yaml:"enable_profiling" json:"enable_profiling"`
+	// Key style of JSON responses: snake (default) or camel
+	JSONCase string `yaml:"json_case" json:"json_case"`
+	// Start with writes rejected; toggled at runtime via PUT /admin/maintenance
+	MaintenanceMode bool `yaml:"maintenance_mode" json:"maintenance_mode"`
+	// Reject every write for good, e.g. when the database is a replica
+	ReadOnly bool `yaml:"read_only" json:"read_only"`
+}
+
+type APIConfig struct {
+	// Page size of list endpoints when no limit is given, and the
+	// largest limit honoured
+	DefaultPageSize int `yaml:"default_page_size" json:"default_page_size"`
+	MaxPageSize     int `yaml:"max_page_size" json:"max_page_size"`
+	// Longest a long-poll request waits for a change before answering empty
+	LongPollTimeout time.Duration `yaml:"longpoll_timeout" json:"longpoll_timeout"`
+}
+
+type DatabaseConfig struct {
+	Host     string `yaml:"host" json:"host"`
+	Port     string `yaml:"port" json:"port"`
+	User     string `yaml:"user" json:"user"`
+	Password string `yaml:"password" json:"password"`
+	Name     string `yaml:"name" json:"name"`
+	// Queries taking at least this long are logged; zero disables it
+	SlowQueryThreshold time.Duration `yaml:"slow_query_threshold" json:"slow_query_threshold"`
+}
+
+type EventsConfig struct {
+	RejectDuplicates bool `yaml:"reject_duplicates" json:"reject_duplicates"`
+	// Nil when the key is absent; zero only rejects events at the same instant
+	DuplicateWindow *time.Duration `yaml:"duplicate_window" json:"duplicate_window"`
+	// Answer a repeated create of the same organizer, name and date with
+	// the existing event instead of 409
+	IdempotentCreate bool `yaml:"idempotent_create" json:"idempotent_create"`
+	MinPaymentTime   int  `yaml:"min_payment_time" json:"min_payment_time"`
+	MaxTotalSeats    int  `yaml:"max_total_seats" json:"max_total_seats"`
+	// How long a reservation holds its seats before it must be booked
+	ReservationTTL time.Duration `yaml:"reservation_ttl" json:"reservation_ttl"`
+}
+
+type AdminConfig struct {
+	Token string `yaml:"token" json:"token"`
+}
+
+type OrganizersConfig struct {
+	// Bearer tokens keyed by organizer ID
+	Tokens map[int]string `yaml:"tokens" json:"tokens"`
+}
+
+type CheckinConfig struct {
+	// Signs booking QR codes; a random key per process when empty
+	SigningKey string `yaml:"signing_key" json:"signing_key"`
+}
+
+type WebhookConfig struct {
+	// Notifications are only logged when empty
+	URL     string        `yaml:"url" json:"url"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+type WorkerConfig struct {
+	// How often expired bookings are cancelled
+	Interval time.Duration `yaml:"interval" json:"interval"`
+	// How often expired reservations are released; shorter than Interval
+	// since reservations live for minutes
+	ReservationInterval time.Duration `yaml:"reservation_interval" json:"reservation_interval"`
+	// How long past its expiry a booking is kept before it is cancelled,
+	// to absorb clock skew; zero cancels on the dot
+	ExpirySkew time.Duration `yaml:"expiry_skew" json:"expiry_skew"`
+}
+
+type LoggingConfig struct {
+	// One of debug, info, warn or error
+	Level string `yaml:"level" json:"level"`
+}
+
+// ApplyDefaults fills in settings that were left unset. Zero and negative
+// numbers count as unset, except for the duplicate window, which is only
+// unset when absent; explicit values are kept as they are.
+func (c *Config) ApplyDefaults() {
+	if strings.TrimSpace(c.Server.Port) == "" {
+		c.Server.Port = DefaultServerPort
+	}
+	if c.Server.JSONCase == "" {
+		c.Server.JSONCase = DefaultJSONCase
+	}
+	if c.API.DefaultPageSize <= 0 {
+		c.API.DefaultPageSize = DefaultPageSize
+	}
+	if c.API.MaxPageSize <= 0 {
+		c.API.MaxPageSize = DefaultMaxPageSize
+	}
+	if c.API.LongPollTimeout <= 0 {
+		c.API.LongPollTimeout = DefaultLongPollTimeout
+	}
+	if c.Events.DuplicateWindow == nil {
+		window := DefaultDuplicateWindow
+		c.Events.DuplicateWindow = &window
+	}
+	if c.Events.MinPaymentTime <= 0 {
+		c.Events.MinPaymentTime = DefaultMinPaymentTime
+	}
+	if c.Events.MaxTotalSeats <= 0 {
+		c.Events.MaxTotalSeats = DefaultMaxTotalSeats
+	}
+	if c.Webhook.Timeout <= 0 {
+		c.Webhook.Timeout = DefaultWebhookTimeout
+	}
+	if c.Worker.Interval <= 0 {
+		c.Worker.Interval = DefaultWorkerInterval
+	}
+	if c.Events.ReservationTTL <= 0 {
+		c.Events.ReservationTTL = DefaultReservationTTL
+	}
+	if c.Worker.ReservationInterval <= 0 {
+		c.Worker.ReservationInterval = DefaultReservationInterval
+	}
+	if c.Logging.Level == "" {
+		c.Logging.Level = DefaultLogLevel
+	}
+}
+
+const redacted = "***"
+
+// Redacted returns a copy of the config with secrets masked.
+func (c Config) Redacted() Config {
+	if c.Database.Password != "" {
+		c.Database.Password = redacted
+	}
+	if c.Admin.Token != "" {
+		c.Admin.Token = redacted
+	}
+	if c.Organizers.Tokens != nil {
+		tokens := make(map[int]string, len(c.Organizers.Tokens))
+		for id := range c.Organizers.Tokens {
+			tokens[id] = redacted
+		}
+		c.Organizers.Tokens = tokens
+	}
+	if c.Checkin.SigningKey != "" {
+		c.Checkin.SigningKey = redacted
+	}
+	return c
+}
+
+var ErrInvalidPort = errors.New("port must be a number from 1 to 65535 or a service name")
+
+// ParsePort trims raw and resolves it to a TCP port number, accepting
+// either digits or a service name such as "http".
+func ParsePort(raw string) (string, error) {
+	port := strings.TrimSpace(raw)
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		if n, err = net.LookupPort("tcp", port); err != nil {
+			return "", fmt.Errorf("%w: %q", ErrInvalidPort, raw)
+		}
+	}
+	if n < 1 || n > 65535 {
+		return "", fmt.Errorf("%w: %q", ErrInvalidPort, raw)
+	}
+	return strconv.Itoa(n), nil
 }
 
 func MustLoadConfig(path string) *Config {
@@ -33,24 +222,486 @@ func MustLoadConfig(path string) *Config {
 	if err := decoder.Decode(&cfg); err != nil {
 		panic(fmt.Errorf("decode config: %v", err))
 	}
+	cfg.ApplyDefaults()
+
+	// The port is joined into the listen address as is, so catch typos here
+	port, err := ParsePort(cfg.Server.Port)
+	if err != nil {
+		panic(fmt.Errorf("server.port: %v", err))
+	}
+	cfg.Server.Port = port
 
 	return &cfg
 }
 
 type Event struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Date        time.Time `json:"date"`
-	TotalSeats  int       `json:"total_seats"`
-	PaymentTime int       `json:"payment_time"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID           int       `json:"id" xml:"id"`
+	Name         string    `json:"name" xml:"name"`
+	Date         time.Time `json:"date" xml:"date"`
+	TotalSeats   int       `json:"total_seats" xml:"total_seats"`
+	PaymentTime  int       `json:"payment_time" xml:"payment_time"`
+	GraceMinutes int       `json:"grace_minutes" xml:"grace_minutes"`
+	OrganizerID  *int      `json:"organizer_id,omitempty" xml:"organizer_id,omitempty"`
+	Timezone     string    `json:"timezone,omitempty" xml:"timezone,omitempty"`
+	// Public responses only say availability is low once fewer seats than this
+	// are left; zero always shows exact counts
+	HideExactBelow int `json:"hide_exact_below,omitempty" xml:"hide_exact_below,omitempty"`
+	// Each user may hold only one pending or confirmed booking
+	OneBookingPerUser bool `json:"one_booking_per_user,omitempty" xml:"one_booking_per_user,omitempty"`
+	// Pending bookings can be cancelled until this many hours before the
+	// event; nil means until the end
+	CancellationDeadlineHours *int `json:"cancellation_deadline_hours,omitempty" xml:"cancellation_deadline_hours,omitempty"`
+	// Set on the instances of a recurring event, see Recurrence
+	SeriesID *int `json:"series_id,omitempty" xml:"series_id,omitempty"`
+	// Optional tiers; when present their totals add up to TotalSeats
+	SeatTypes []SeatType `json:"seat_types,omitempty" xml:"seat_types>seat_type,omitempty"`
+	// Organizer-defined fields such as a sponsor or age rating; a JSON object
+	Metadata  json.RawMessage `json:"metadata,omitempty" xml:"metadata,omitempty"`
+	CreatedAt time.Time       `json:"created_at" xml:"created_at"`
+}
+
+// Recurrence frequencies
+const (
+	FrequencyDaily  = "daily"
+	FrequencyWeekly = "weekly"
+)
+
+// Recurrence describes how a recurring event repeats: every Interval days or
+// weeks, for Count instances or until the last one on or before Until.
+type Recurrence struct {
+	Frequency string     `json:"frequency"`
+	Interval  int        `json:"interval"`
+	Count     int        `json:"count"`
+	Until     *time.Time `json:"until"`
+}
+
+// Dates returns the instance dates starting with first, at most limit of
+// them. Steps are taken in first's location, so the wall-clock time stays the
+// same across daylight saving changes.
+func (r Recurrence) Dates(first time.Time, limit int) []time.Time {
+	step := max(r.Interval, 1)
+	if r.Frequency == FrequencyWeekly {
+		step *= 7
+	}
+
+	var dates []time.Time
+	for i := 0; len(dates) < limit; i++ {
+		if r.Count > 0 && i >= r.Count {
+			break
+		}
+		date := first.AddDate(0, 0, i*step)
+		if r.Until != nil && date.After(*r.Until) {
+			break
+		}
+		dates = append(dates, date)
+	}
+	return dates
+}
+
+// EventExport is one line of the events backup: the event with its seat
+// types and, when requested, its bookings.
+type EventExport struct {
+	Event
+	Bookings []Booking `json:"bookings,omitempty" xml:"bookings>booking,omitempty"`
+}
+
+// UnmarshalJSON decodes the event with Event.UnmarshalJSON, which would
+// otherwise be promoted and drop the bookings.
+func (x *EventExport) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &x.Event); err != nil {
+		return err
+	}
+	var aux struct {
+		Bookings []Booking `json:"bookings"`
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	x.Bookings = aux.Bookings
+	return nil
+}
+
+// ImportResult counts what an events import did with each event.
+type ImportResult struct {
+	Inserted int `json:"inserted" xml:"inserted"`
+	Updated  int `json:"updated" xml:"updated"`
+	Skipped  int `json:"skipped" xml:"skipped"`
+}
+
+// SeatRecount is an event's confirmed seat counter before and after it was
+// recalculated from its bookings.
+type SeatRecount struct {
+	EventID int   `json:"event_id" xml:"event_id"`
+	Before  int64 `json:"before" xml:"before"`
+	After   int64 `json:"after" xml:"after"`
+}
+
+// Checks run by an integrity verification
+const (
+	// The event's confirmed seat counter exceeds its capacity
+	CheckOverconfirmedEvent = "overconfirmed_event"
+	// A pending or confirmed booking whose event no longer exists
+	CheckOrphanedBooking = "orphaned_booking"
+)
+
+// IntegrityViolation is one broken invariant found by an integrity check.
+type IntegrityViolation struct {
+	Check     string `json:"check" xml:"check"`
+	EventID   *int   `json:"event_id,omitempty" xml:"event_id,omitempty"`
+	BookingID *int   `json:"booking_id,omitempty" xml:"booking_id,omitempty"`
+	Detail    string `json:"detail" xml:"detail"`
+}
+
+// IntegrityReport is the outcome of checking bookings against event
+// availability. It is healthy when Violations is empty.
+type IntegrityReport struct {
+	EventsChecked int                  `json:"events_checked" xml:"events_checked"`
+	Violations    []IntegrityViolation `json:"violations" xml:"violations>violation"`
+}
+
+// UtilizationPoint is one bucket of an event's confirmation history: the
+// seats confirmed within the bucket and the running total up to its end.
+type UtilizationPoint struct {
+	Bucket     time.Time `json:"bucket" xml:"bucket"`
+	Seats      int64     `json:"seats" xml:"seats"`
+	Cumulative int64     `json:"cumulative_seats" xml:"cumulative_seats"`
+}
+
+// SeatType is a tier of an event's seats. Price is in minor currency units.
+type SeatType struct {
+	Type  string `json:"type" xml:"type"`
+	Total int    `json:"total" xml:"total"`
+	Price int    `json:"price" xml:"price"`
+}
+
+// SeatTypeAvailability is a seat type with its seats not yet confirmed.
+type SeatTypeAvailability struct {
+	SeatType
+	Available int64 `json:"available_seats" xml:"available_seats"`
+}
+
+// Event dates outside this range are rejected as implausible input.
+const (
+	MinEventYear = 1970
+	MaxEventYear = 2200
+)
+
+var (
+	ErrDateOutOfRange  = errors.New("date is out of range")
+	ErrInvalidTimezone = errors.New("invalid timezone")
+)
+
+// localDateLayout is a wall-clock date without offset, accepted only when the
+// event names its timezone.
+const localDateLayout = "2006-01-02T15:04:05.999999999"
+
+// ParseEventDate parses an RFC 3339 event date into UTC and rejects years
+// outside [MinEventYear, MaxEventYear].
+func ParseEventDate(value string) (time.Time, error) {
+	return ParseEventDateIn(value, nil)
+}
+
+// ParseEventDateIn is ParseEventDate that also accepts a date without offset
+// as wall-clock time in loc, when loc is not nil.
+func ParseEventDateIn(value string, loc *time.Location) (time.Time, error) {
+	date, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil && loc != nil {
+		date, err = time.ParseInLocation(localDateLayout, value, loc)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %v", err)
+	}
+	// time.Parse accepts offsets such as +24:00 that time.Time can't marshal back
+	if _, offset := date.Zone(); offset <= -24*60*60 || offset >= 24*60*60 {
+		return time.Time{}, fmt.Errorf("invalid date: timezone offset out of range")
+	}
+	date = date.UTC()
+	if year := date.Year(); year < MinEventYear || year > MaxEventYear {
+		return time.Time{}, fmt.Errorf("%w: year must be between %d and %d", ErrDateOutOfRange, MinEventYear, MaxEventYear)
+	}
+	return date, nil
+}
+
+// LoadTimezone resolves an IANA timezone name; the empty name is UTC.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	// LoadLocation also accepts "Local", which depends on the server
+	if name == "Local" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTimezone, name)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTimezone, name)
+	}
+	return loc, nil
+}
+
+// UnmarshalJSON decodes an event, parsing the date with ParseEventDateIn.
+// With a timezone the date may be given as local wall-clock time.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	type plain Event
+	aux := struct {
+		*plain
+		Date *string `json:"date"`
+	}{plain: (*plain)(e)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	// An explicit null means no metadata, not a JSON null to store
+	if string(e.Metadata) == "null" {
+		e.Metadata = nil
+	}
+
+	var loc *time.Location
+	if e.Timezone != "" {
+		var err error
+		if loc, err = LoadTimezone(e.Timezone); err != nil {
+			return err
+		}
+	}
+
+	if aux.Date != nil {
+		date, err := ParseEventDateIn(*aux.Date, loc)
+		if err != nil {
+			return err
+		}
+		e.Date = date
+	}
+	e.Localize()
+	return nil
+}
+
+// LowAvailability reports whether available is below the event's
+// HideExactBelow threshold.
+func (e *Event) LowAvailability(available int64) bool {
+	return available < int64(e.HideExactBelow)
+}
+
+// Localize expresses Date in the event's timezone. Unknown zones leave it as is.
+func (e *Event) Localize() {
+	if loc, err := LoadTimezone(e.Timezone); err == nil {
+		e.Date = e.Date.In(loc)
+	}
+}
+
+// EventPatch holds the event fields a PATCH request changes; nil fields are
+// left as they are.
+type EventPatch struct {
+	Name           *string    `json:"name"`
+	Date           *time.Time `json:"date"`
+	TotalSeats     *int       `json:"total_seats"`
+	PaymentTime    *int       `json:"payment_time"`
+	GraceMinutes   *int       `json:"grace_minutes"`
+	HideExactBelow *int       `json:"hide_exact_below"`
+
+	CancellationDeadlineHours *int `json:"cancellation_deadline_hours"`
+}
+
+// UnmarshalJSON decodes a patch, parsing the date with ParseEventDate.
+func (p *EventPatch) UnmarshalJSON(data []byte) error {
+	type plain EventPatch
+	aux := struct {
+		*plain
+		Date *string `json:"date"`
+	}{plain: (*plain)(p)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Date != nil {
+		date, err := ParseEventDate(*aux.Date)
+		if err != nil {
+			return err
+		}
+		p.Date = &date
+	}
+	return nil
+}
+
+// Empty reports whether the patch changes nothing.
+func (p EventPatch) Empty() bool {
+	return p.Name == nil && p.Date == nil && p.TotalSeats == nil && p.PaymentTime == nil && p.GraceMinutes == nil &&
+		p.HideExactBelow == nil && p.CancellationDeadlineHours == nil
+}
+
+// EventFilter narrows event listings. The zero value lists upcoming events
+// of every organizer.
+type EventFilter struct {
+	IncludePast bool
+	OrganizerID *int
+	SeriesID    *int
+	// Bounds on when the event was created, inclusive; zero means unbounded
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// Metadata values required by key, compared as text
+	Metadata map[string]string
+	// Text searched for in event names; matches are listed most relevant
+	// first instead of by date
+	Query string
+}
+
+// EventCursor identifies a position in the events list ordered by (date, id).
+type EventCursor struct {
+	Date time.Time
+	ID   int
+}
+
+// SeatCounts breaks an event's capacity down by booking state. Pending seats
+// are taken from Available while their hold lasts; Pending also counts
+// lapsed holds the cleanup hasn't cancelled yet.
+type SeatCounts struct {
+	Available int64 `json:"available_seats" xml:"available_seats"`
+	Confirmed int64 `json:"confirmed_seats" xml:"confirmed_seats"`
+	Pending   int64 `json:"pending_seats" xml:"pending_seats"`
+	// Held by unexpired reservations; already left out of Available
+	Reserved int64 `json:"reserved_seats" xml:"reserved_seats"`
+}
+
+// SeatAvailability is an event's available seats together with the
+// threshold below which the exact number is hidden from the public.
+type SeatAvailability struct {
+	Available      int64
+	HideExactBelow int
+}
+
+// Low reports whether the exact number should be hidden.
+func (a SeatAvailability) Low() bool {
+	return (&Event{HideExactBelow: a.HideExactBelow}).LowAvailability(a.Available)
+}
+
+// BookingStatus is the lifecycle state of a booking. The bookings table
+// constrains status to these values.
+type BookingStatus string
+
+const (
+	BookingPending   BookingStatus = "pending"
+	BookingConfirmed BookingStatus = "confirmed"
+	BookingCancelled BookingStatus = "cancelled"
+)
+
+var ErrInvalidStatusTransition = errors.New("invalid booking status transition")
+
+// bookingTransitions lists the statuses each status may move to. Confirmed
+// bookings return to pending when an event is rescheduled and are cancelled
+// when refunded.
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	BookingPending:   {BookingConfirmed, BookingCancelled},
+	BookingConfirmed: {BookingPending, BookingCancelled},
+	BookingCancelled: nil,
+}
+
+// CancelReason records why a booking was cancelled. The bookings table
+// constrains it to these values.
+type CancelReason string
+
+const (
+	CancelReasonUserRequest CancelReason = "user_request"
+	CancelReasonRefunded    CancelReason = "refunded"
+	CancelReasonExpired     CancelReason = "expired"
+	// Seats given back when only part of a booking was confirmed
+	CancelReasonReleased CancelReason = "released"
+	// Replaced by a booking on another event
+	CancelReasonMoved CancelReason = "moved"
+)
+
+// Valid reports whether s is a known status.
+func (s BookingStatus) Valid() bool {
+	_, ok := bookingTransitions[s]
+	return ok
+}
+
+// ValidateTransition reports whether a booking may move from one status to
+// another.
+func ValidateTransition(from, to BookingStatus) error {
+	if !to.Valid() || !slices.Contains(bookingTransitions[from], to) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidStatusTransition, from, to)
+	}
+	return nil
 }
 
 type Booking struct {
-	ID        int       `json:"id"`
-	EventID   int       `json:"event_id"`
-	UserName  string    `json:"user_name"`
-	Seats     int       `json:"seats"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID       int    `json:"id" xml:"id"`
+	EventID  int    `json:"event_id" xml:"event_id"`
+	UserName string `json:"user_name" xml:"user_name"`
+	Seats    int    `json:"seats" xml:"seats"`
+	// Request only: the fewest seats the booker accepts when Seats aren't
+	// all free. Seats then holds the number actually booked.
+	MinSeats int           `json:"min_seats,omitempty" xml:"min_seats,omitempty"`
+	Status   BookingStatus `json:"status" xml:"status"`
+	SeatType string        `json:"seat_type,omitempty" xml:"seat_type,omitempty"`
+	// Public handle for the booking, e.g. on tickets and at the door
+	Reference string    `json:"reference,omitempty" xml:"reference,omitempty"`
+	CreatedAt time.Time `json:"created_at" xml:"created_at"`
+	// Set when the booking was last confirmed
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty" xml:"confirmed_at,omitempty"`
+	// Set when the attendee is checked in at the door
+	CheckedInAt *time.Time `json:"checked_in_at,omitempty" xml:"checked_in_at,omitempty"`
+	// Why a cancelled booking was cancelled; empty for older cancellations
+	CancelReason CancelReason `json:"cancel_reason,omitempty" xml:"cancel_reason,omitempty"`
+	// Bumped on every change of state; the booking's ETag is built from it
+	Version int `json:"version,omitempty" xml:"version,omitempty"`
+	// Returned only to the booker; the database keeps a hash
+	ConfirmToken string `json:"confirm_token,omitempty" xml:"confirm_token,omitempty"`
+}
+
+// Reservation is a short hold on seats ahead of booking them. Unlike a
+// pending booking it counts against availability until it expires or is
+// promoted to a booking.
+type Reservation struct {
+	ID        int       `json:"id" xml:"id"`
+	EventID   int       `json:"event_id" xml:"event_id"`
+	UserName  string    `json:"user_name" xml:"user_name"`
+	Seats     int       `json:"seats" xml:"seats"`
+	ExpiresAt time.Time `json:"expires_at" xml:"expires_at"`
+	CreatedAt time.Time `json:"created_at" xml:"created_at"`
+	// Returned only to the reserver, who needs it to promote the reservation
+	Token string `json:"token,omitempty" xml:"token,omitempty"`
+}
+
+// ExpiredBooking is a pending booking whose payment window has closed but
+// which the cleanup worker hasn't cancelled yet.
+type ExpiredBooking struct {
+	Booking
+	ExpiresAt time.Time `json:"expires_at" xml:"expires_at"`
+}
+
+// Receipt is the printable record of a confirmed booking. Prices are set
+// only for bookings of a seat type.
+type Receipt struct {
+	Reference   string    `json:"reference" xml:"reference"`
+	EventID     int       `json:"event_id" xml:"event_id"`
+	EventName   string    `json:"event_name" xml:"event_name"`
+	EventDate   time.Time `json:"event_date" xml:"event_date"`
+	UserName    string    `json:"user_name" xml:"user_name"`
+	Seats       int       `json:"seats" xml:"seats"`
+	SeatType    string    `json:"seat_type,omitempty" xml:"seat_type,omitempty"`
+	UnitPrice   *int      `json:"unit_price,omitempty" xml:"unit_price,omitempty"`
+	TotalPrice  *int64    `json:"total_price,omitempty" xml:"total_price,omitempty"`
+	ConfirmedAt time.Time `json:"confirmed_at" xml:"confirmed_at"`
+}
+
+// BookingState is a booking's current status. ExpiresAt is set only while
+// the booking is pending.
+type BookingState struct {
+	ID        int           `json:"id" xml:"id"`
+	Status    BookingStatus `json:"status" xml:"status"`
+	ExpiresAt *time.Time    `json:"expires_at" xml:"expires_at"`
+}
+
+// WaitlistEntry is a user waiting for seats on an event. Position counts
+// from 1 among the entries still waiting, in the order they joined.
+type WaitlistEntry struct {
+	ID       int       `json:"id" xml:"id"`
+	EventID  int       `json:"event_id" xml:"event_id"`
+	UserName string    `json:"user_name" xml:"user_name"`
+	Position int       `json:"position" xml:"position"`
+	JoinedAt time.Time `json:"joined_at" xml:"joined_at"`
+}
+
+type GroupMember struct {
+	UserName string `json:"user_name" xml:"user_name"`
+	Seats    int    `json:"seats" xml:"seats"`
 }
@@ -0,0 +1,297 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEventDate_RejectsBadInput(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"tomorrow",
+		"2030-02-30T10:00:00Z",
+		"2030-06-30T23:59:60Z",
+		"2030-13-01T10:00:00Z",
+		"2030-01-01T10:00:00",
+		"2030-01-01T10:00:00+25:00",
+		"2030-01-01T10:00:00+24:00",
+	} {
+		_, err := ParseEventDate(value)
+		assert.Error(t, err, value)
+		assert.NotErrorIs(t, err, ErrDateOutOfRange, value)
+	}
+
+	for _, value := range []string{"9999-12-31T23:59:59Z", "0001-01-01T00:00:00Z", "1969-12-31T23:00:00Z"} {
+		_, err := ParseEventDate(value)
+		assert.ErrorIs(t, err, ErrDateOutOfRange, value)
+	}
+
+	date, err := ParseEventDate("2030-05-01T19:30:00+02:00")
+	require.NoError(t, err)
+	assert.True(t, date.Equal(time.Date(2030, 5, 1, 17, 30, 0, 0, time.UTC)))
+}
+
+func TestEvent_UnmarshalJSON(t *testing.T) {
+	var event Event
+	err := json.Unmarshal([]byte(`{"name":"Concert","date":"2030-05-01T19:30:00Z","total_seats":10}`), &event)
+	require.NoError(t, err)
+	assert.Equal(t, "Concert", event.Name)
+	assert.Equal(t, 10, event.TotalSeats)
+	assert.Equal(t, 2030, event.Date.Year())
+
+	err = json.Unmarshal([]byte(`{"name":"Concert","date":"9999-05-01T19:30:00Z"}`), &event)
+	assert.ErrorIs(t, err, ErrDateOutOfRange)
+
+	err = json.Unmarshal([]byte(`{"name":"Concert","date":12}`), &event)
+	assert.Error(t, err)
+}
+
+func FuzzParseEventDate(f *testing.F) {
+	for _, seed := range []string{
+		"2030-05-01T19:30:00Z",
+		"2030-05-01T19:30:00.123456789+02:00",
+		"2030-06-30T23:59:60Z",
+		"9999-12-31T23:59:59Z",
+		"0000-01-01T00:00:00Z",
+		"2030-01-01T00:00:00-23:59",
+		"",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, value string) {
+		date, err := ParseEventDate(value)
+		parsed, parseErr := time.Parse(time.RFC3339Nano, value)
+		if parseErr != nil {
+			if err == nil {
+				t.Fatalf("ParseEventDate(%q) accepted what time.Parse rejects: %v", value, parseErr)
+			}
+			return
+		}
+
+		// Past time.Parse, only the offset and year bounds may reject a date
+		_, offset := parsed.Zone()
+		offsetOK := offset > -24*60*60 && offset < 24*60*60
+		year := parsed.UTC().Year()
+		yearOK := year >= MinEventYear && year <= MaxEventYear
+		switch {
+		case err == nil && (!offsetOK || !yearOK):
+			t.Fatalf("ParseEventDate(%q) accepted offset %d, year %d", value, offset, year)
+		case err != nil && offsetOK && yearOK:
+			t.Fatalf("ParseEventDate(%q) rejected a valid date: %v", value, err)
+		case err != nil && offsetOK && !errors.Is(err, ErrDateOutOfRange):
+			t.Fatalf("ParseEventDate(%q) rejected year %d without ErrDateOutOfRange: %v", value, year, err)
+		case err != nil:
+			return
+		}
+
+		if !date.Equal(parsed) || date.Location() != time.UTC {
+			t.Fatalf("ParseEventDate(%q) = %v, want %v in UTC", value, date, parsed)
+		}
+
+		// Accepted dates survive a JSON round trip unchanged
+		data, err := json.Marshal(Event{Date: date})
+		if err != nil {
+			t.Fatalf("marshal %q: %v", value, err)
+		}
+		var event Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			t.Fatalf("round trip of %q: %v", value, err)
+		}
+		if !event.Date.Equal(date) {
+			t.Fatalf("round trip of %q changed date: %v != %v", value, event.Date, date)
+		}
+	})
+}
+
+func TestEvent_TimezoneRoundTrip(t *testing.T) {
+	var event Event
+	err := json.Unmarshal([]byte(`{"name":"Opera","date":"2030-06-01T20:00:00","timezone":"Europe/Moscow"}`), &event)
+	require.NoError(t, err)
+
+	// 20:00 in Moscow (UTC+3) is 17:00 UTC
+	assert.True(t, event.Date.Equal(time.Date(2030, 6, 1, 17, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "Europe/Moscow", event.Date.Location().String())
+
+	data, err := json.Marshal(event)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"date":"2030-06-01T20:00:00+03:00"`)
+
+	// Explicit offsets still win over the zone
+	err = json.Unmarshal([]byte(`{"date":"2030-06-01T20:00:00Z","timezone":"America/New_York"}`), &event)
+	require.NoError(t, err)
+	assert.True(t, event.Date.Equal(time.Date(2030, 6, 1, 20, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 16, event.Date.Hour())
+
+	// Without a zone a wall-clock time is ambiguous
+	err = json.Unmarshal([]byte(`{"date":"2030-06-01T20:00:00"}`), &Event{})
+	assert.Error(t, err)
+
+	for _, name := range []string{"Mars/Olympus_Mons", "Local"} {
+		err = json.Unmarshal([]byte(`{"date":"2030-06-01T20:00:00","timezone":"`+name+`"}`), &Event{})
+		assert.ErrorIs(t, err, ErrInvalidTimezone, name)
+	}
+}
+
+func TestEventExport_UnmarshalJSON(t *testing.T) {
+	line := `{"id":3,"name":"Concert","date":"2030-01-01T20:00:00","timezone":"Europe/Berlin","total_seats":10,"payment_time":30,` +
+		`"seat_types":[{"type":"vip","total":10,"price":5}],"bookings":[{"id":9,"user_name":"john","seats":2,"status":"confirmed"}]}`
+
+	var export EventExport
+	require.NoError(t, json.Unmarshal([]byte(line), &export))
+	assert.Equal(t, 3, export.ID)
+	assert.Equal(t, "2030-01-01T19:00:00Z", export.Date.UTC().Format(time.RFC3339))
+	assert.Len(t, export.SeatTypes, 1)
+	require.Len(t, export.Bookings, 1)
+	assert.Equal(t, BookingConfirmed, export.Bookings[0].Status)
+}
+
+func TestEvent_LowAvailability(t *testing.T) {
+	event := Event{HideExactBelow: 5}
+	assert.True(t, event.LowAvailability(4))
+	assert.True(t, event.LowAvailability(0))
+	assert.False(t, event.LowAvailability(5))
+	assert.False(t, event.LowAvailability(50))
+
+	// Zero disables hiding, even when sold out
+	assert.False(t, (&Event{}).LowAvailability(0))
+}
+
+func TestRecurrence_Dates(t *testing.T) {
+	first := time.Date(2030, 1, 7, 18, 0, 0, 0, time.UTC)
+
+	dates := Recurrence{Frequency: FrequencyWeekly, Count: 3}.Dates(first, 100)
+	assert.Equal(t, []time.Time{first, first.AddDate(0, 0, 7), first.AddDate(0, 0, 14)}, dates)
+
+	dates = Recurrence{Frequency: FrequencyDaily, Interval: 2, Count: 3}.Dates(first, 100)
+	assert.Equal(t, []time.Time{first, first.AddDate(0, 0, 2), first.AddDate(0, 0, 4)}, dates)
+
+	// Until is inclusive
+	until := first.AddDate(0, 0, 14)
+	dates = Recurrence{Frequency: FrequencyWeekly, Until: &until}.Dates(first, 100)
+	assert.Len(t, dates, 3)
+
+	// The limit caps open-ended rules
+	until = first.AddDate(10, 0, 0)
+	assert.Len(t, Recurrence{Frequency: FrequencyDaily, Until: &until}.Dates(first, 5), 5)
+
+	// A weekly class keeps its local start time across the DST change
+	berlin, err := LoadTimezone("Europe/Berlin")
+	require.NoError(t, err)
+	first = time.Date(2030, 3, 24, 19, 0, 0, 0, berlin)
+	dates = Recurrence{Frequency: FrequencyWeekly, Count: 2}.Dates(first, 100)
+	require.Len(t, dates, 2)
+	assert.Equal(t, 19, dates[1].Hour())
+	assert.Equal(t, 7*24*time.Hour-time.Hour, dates[1].Sub(dates[0]))
+}
+
+func TestValidateTransition(t *testing.T) {
+	for _, tc := range []struct {
+		from, to BookingStatus
+		ok       bool
+	}{
+		{BookingPending, BookingConfirmed, true},
+		{BookingPending, BookingCancelled, true},
+		{BookingConfirmed, BookingPending, true},
+		{BookingConfirmed, BookingCancelled, true},
+		{BookingCancelled, BookingConfirmed, false},
+		{BookingCancelled, BookingPending, false},
+		{BookingPending, BookingPending, false},
+		{BookingPending, "refunded", false},
+		{"refunded", BookingConfirmed, false},
+	} {
+		err := ValidateTransition(tc.from, tc.to)
+		if tc.ok {
+			assert.NoError(t, err, "%s -> %s", tc.from, tc.to)
+		} else {
+			assert.ErrorIs(t, err, ErrInvalidStatusTransition, "%s -> %s", tc.from, tc.to)
+		}
+	}
+
+	assert.True(t, BookingCancelled.Valid())
+	assert.False(t, BookingStatus("checked_in").Valid())
+	assert.False(t, BookingStatus("").Valid())
+}
+
+func TestConfig_ApplyDefaults(t *testing.T) {
+	var cfg Config
+	cfg.ApplyDefaults()
+	assert.Equal(t, DefaultServerPort, cfg.Server.Port)
+	assert.Equal(t, DefaultJSONCase, cfg.Server.JSONCase)
+	assert.Equal(t, DefaultPageSize, cfg.API.DefaultPageSize)
+	assert.Equal(t, DefaultMaxPageSize, cfg.API.MaxPageSize)
+	assert.Equal(t, DefaultLongPollTimeout, cfg.API.LongPollTimeout)
+	assert.Equal(t, DefaultMinPaymentTime, cfg.Events.MinPaymentTime)
+	assert.Equal(t, DefaultMaxTotalSeats, cfg.Events.MaxTotalSeats)
+	assert.Equal(t, DefaultDuplicateWindow, *cfg.Events.DuplicateWindow)
+	assert.Equal(t, DefaultWebhookTimeout, cfg.Webhook.Timeout)
+	assert.Equal(t, DefaultWorkerInterval, cfg.Worker.Interval)
+	assert.Equal(t, DefaultReservationTTL, cfg.Events.ReservationTTL)
+	assert.Equal(t, DefaultReservationInterval, cfg.Worker.ReservationInterval)
+	assert.Equal(t, DefaultLogLevel, cfg.Logging.Level)
+
+	window := time.Minute
+	explicit := Config{
+		Server:  ServerConfig{Port: "9090", JSONCase: "camel"},
+		API:     APIConfig{DefaultPageSize: 5, MaxPageSize: 50, LongPollTimeout: 10 * time.Second},
+		Events:  EventsConfig{MinPaymentTime: 15, MaxTotalSeats: 500, DuplicateWindow: &window, ReservationTTL: time.Minute},
+		Webhook: WebhookConfig{Timeout: time.Second},
+		Worker:  WorkerConfig{Interval: 10 * time.Second, ReservationInterval: 5 * time.Second},
+		Logging: LoggingConfig{Level: "debug"},
+	}
+	want := explicit
+	explicit.ApplyDefaults()
+	assert.Equal(t, want, explicit)
+
+	var zero time.Duration
+	exact := Config{Events: EventsConfig{DuplicateWindow: &zero}}
+	exact.ApplyDefaults()
+	assert.Equal(t, time.Duration(0), *exact.Events.DuplicateWindow)
+}
+
+func TestMustLoadConfig_Sections(t *testing.T) {
+	// The shipped config keeps loading into the named sections
+	cfg := MustLoadConfig("../config.yaml")
+	assert.Equal(t, "8080", cfg.Server.Port)
+	assert.Equal(t, "db", cfg.Database.Host)
+	assert.Equal(t, 100, cfg.API.MaxPageSize)
+	assert.Equal(t, time.Hour, *cfg.Events.DuplicateWindow)
+	assert.Equal(t, time.Minute, cfg.Worker.Interval)
+	assert.Equal(t, "info", cfg.Logging.Level)
+}
+
+func TestParsePort(t *testing.T) {
+	for raw, want := range map[string]string{
+		"8080":    "8080",
+		" 8080 ":  "8080",
+		"\t443\n": "443",
+		"65535":   "65535",
+		"http":    "80",
+	} {
+		port, err := ParsePort(raw)
+		require.NoError(t, err, raw)
+		assert.Equal(t, want, port, raw)
+	}
+
+	for _, raw := range []string{"", "0", "65536", "-1", "80 80", "no-such-service"} {
+		_, err := ParsePort(raw)
+		assert.ErrorIs(t, err, ErrInvalidPort, raw)
+	}
+}
+
+func TestMustLoadConfig_Port(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	require.NoError(t, os.WriteFile(path, []byte("server:\n  port: \"9090 \"\n"), 0o600))
+	assert.Equal(t, "9090", MustLoadConfig(path).Server.Port)
+
+	require.NoError(t, os.WriteFile(path, []byte("server:\n  port: \"eighty\"\n"), 0o600))
+	assert.Panics(t, func() { MustLoadConfig(path) })
+}